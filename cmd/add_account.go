@@ -17,11 +17,12 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"os"
 	"slices"
 	"strconv"
+	"time"
 
 	"github.com/EugeneShtoka/figoro/lib/gaccount"
 	"github.com/EugeneShtoka/figoro/lib/gaseed"
@@ -37,15 +38,27 @@ var (
 	defaultPort int = 58080
 	minPort = 1024
 	maxPort = 65535
-	credFile string
 	accountsConfigKey = "accounts"
+	credentialsConfigKey = "credentials"
+	authModeConfigKey = "auth.mode"
+	authTimeoutConfigKey = "auth.timeout"
+	defaultAuthTimeout = 5 * time.Minute
+	authMode string
+	authTimeout time.Duration
 )
 
 var addAccountCmd = &cobra.Command{
 	Use:   "account [account name (at least 3 letters)]",
 	Args:  cobra.ExactArgs(1),
 	Short: "Add account",
-	Long: "Add account. Requires account name to delete",
+	Long: `Add account. Requires account name to add. The OAuth client is read from the credentials file
+downloaded from Google Cloud Console, given by --credentials or the credentials config key,
+otherwise from clientID and clientSecret in config. For example:
+
+figoro add account work --credentials ~/Downloads/client_secret_123.apps.googleusercontent.com.json
+
+On machines without a browser use --auth-mode headless to open the URL on another machine
+and paste the redirect URL or the code back.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		err := addAccount(args[0], &logger)
 		if (err != nil) {
@@ -67,15 +80,11 @@ func addAccount(accName string, logger *zerolog.Logger) error {
 		return fmt.Errorf("account '%s' already exists in config", accName)
 	}
 
-	clientID, err := getStringProperty("clientID", 60, 100)
-	if (err != nil) {
-		return err
-	}
-	clientSecret, err := getStringProperty("clientSecret", 30, 40)
+	port, err := getPort()
 	if (err != nil) {
 		return err
 	}
-	port, err := getPort()
+	clientID, clientSecret, err := getClient(port)
 	if (err != nil) {
 		return err
 	}
@@ -164,9 +173,71 @@ func getStringProperty(name string, minLength int, maxLength int) (string, error
 	return value, nil
 }
 
+// getClient reads the OAuth client from the credentials file when one is given,
+// otherwise from clientID and clientSecret in config, prompting for missing values
+func getClient(port int) (string, string, error) {
+	path := viper.GetString(credentialsConfigKey)
+	if (path == "") {
+		clientID, err := getStringProperty("clientID", 60, 100)
+		if (err != nil) {
+			return "", "", err
+		}
+		clientSecret, err := getStringProperty("clientSecret", 30, 40)
+		if (err != nil) {
+			return "", "", err
+		}
+		return clientID, clientSecret, nil
+	}
+
+	credentials, err := gaseed.LoadCredentials(path)
+	if (err != nil) {
+		return "", "", err
+	}
+	err = credentials.ValidateRedirectURL(gauth.RedirectURL(fmt.Sprintf("%d", port)))
+	if (err != nil) {
+		return "", "", fmt.Errorf("credentials file '%s' does not match port %d: %w", path, port, err)
+	}
+	return credentials.ClientID, credentials.ClientSecret, nil
+}
+
+// addAuthFlags adds flags selecting how the user grants access, values from config are used when they are not set
+func addAuthFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&authMode, "auth-mode", "", fmt.Sprintf("how to grant access %v (default headless over ssh without display, browser otherwise)", gauth.Modes))
+	cmd.Flags().DurationVar(&authTimeout, "auth-timeout", 0, fmt.Sprintf("how long to wait for access to be granted (default %s)", defaultAuthTimeout))
+}
+
+func getAuthMode() (string, error) {
+	mode := authMode
+	if (mode == "") {
+		mode = viper.GetString(authModeConfigKey)
+	}
+	if (mode == "") {
+		mode = gauth.ModeBrowser
+		if (os.Getenv("SSH_CONNECTION") != "" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "") {
+			mode = gauth.ModeHeadless
+		}
+	}
+	if (!slices.Contains(gauth.Modes, mode)) {
+		return "", fmt.Errorf("unknown auth mode '%s', expected one of %v", mode, gauth.Modes)
+	}
+	return mode, nil
+}
+
 func authorize(clientID string, clientSecret string, port string, logger *zerolog.Logger) (*gaseed.GASeed, error) {
+	mode, err := getAuthMode()
+	if (err != nil) {
+		return nil, err
+	}
+
 	server := gauth.New(clientID, clientSecret, port, logger)
-	seed, err := server.Authorize(context.Background())
+	server.Mode = mode
+	server.Timeout = authTimeout
+	if (server.Timeout == 0) {
+		server.Timeout = viper.GetDuration(authTimeoutConfigKey)
+	}
+
+	// the context of the command is cancelled by Ctrl+C
+	seed, err := server.Authorize(rootCmd.Context())
 	if err != nil {
 		return nil, fmt.Errorf("failed to authorize: %w", err)
 	}
@@ -174,6 +245,41 @@ func authorize(clientID string, clientSecret string, port string, logger *zerolo
 	return seed, nil
 }
 
+// reauthorizeAccount runs the auth flow again with the client of the stored seed
+// and replaces the seed in the keyring, leaving the account config untouched.
+// When the seed is missing from the keyring, the client is taken from config or prompted for
+func reauthorizeAccount(accName string, logger *zerolog.Logger) (*gaseed.GASeed, error) {
+	keyring := typedkeyring.New[gaseed.GASeed](serviceName)
+	port, err := getPort()
+	if (err != nil) {
+		return nil, err
+	}
+
+	var clientID, clientSecret string
+	oldSeed, err := keyring.Load(accName)
+	if (err == nil) {
+		clientID, clientSecret = oldSeed.Config.ClientID, oldSeed.Config.ClientSecret
+	} else {
+		logger.Warn().Err(err).Str("account", accName).Msg("stored token is not available, using client from config")
+		clientID, clientSecret, err = getClient(port)
+		if (err != nil) {
+			return nil, err
+		}
+	}
+
+	seed, err := authorize(clientID, clientSecret, fmt.Sprintf("%d", port), logger)
+	if (err != nil) {
+		return nil, err
+	}
+
+	err = keyring.Save(accName, seed)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to save token %s to keyring: %w", accName, err)
+	}
+
+	return seed, nil
+}
+
 func saveAccount(accountName string, seed *gaseed.GASeed) error {
 	keyring := typedkeyring.New[gaseed.GASeed](serviceName)
 	err := keyring.Save(accountName, seed)
@@ -202,15 +308,19 @@ func updateConfigFile(account *gaccount.GAccount) error {
 	accounts = append(accounts, *account)
 
 	viper.Set(accountsConfigKey, accounts)
-	return viper.WriteConfig()
+	return writeConfig()
 }
 
 func init() {
 	addCmd.AddCommand(addAccountCmd)
 	
 	addAccountCmd.Flags().IntP("port", "p", defaultPort, "port number for gAuth code response")
-	addAccountCmd.Flags().StringVar(&credFile, "credentials", "", "path to credentials file")
+	addAccountCmd.Flags().String("credentials", "", "path to client_secret_*.json file downloaded from Google Cloud Console")
+
+	addAuthFlags(addAccountCmd)
 
 	viper.SetDefault("port", defaultPort)
+	viper.SetDefault(authTimeoutConfigKey, defaultAuthTimeout)
 	viper.BindPFlag("port", addAccountCmd.Flags().Lookup("port"))
+	viper.BindPFlag(credentialsConfigKey, addAccountCmd.Flags().Lookup("credentials"))
 }
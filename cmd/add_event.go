@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	addEventTarget	*eventTarget
+	addEventFields	*eventFlags
+)
+
+var addEventCmd = &cobra.Command{
+	Use:   "event",
+	Args:  cobra.NoArgs,
+	Short: "Add event",
+	Long: `Add event to a calendar of an account. For example:
+
+figoro add event -a work -t "Planning" --start "2024-05-06 10:00" --end "2024-05-06 11:00" --attendees bob@example.com
+
+figoro add event -a home -t "Holiday" --all-day --start 2024-07-01 --end 2024-07-15`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := addEvent()
+		if (err != nil) {
+			showError("failed to add event", err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	addCmd.AddCommand(addEventCmd)
+
+	addEventTarget = newEventTarget(addEventCmd, true)
+	addEventFields = newEventFlags(addEventCmd)
+}
+
+func addEvent() error {
+	event, err := addEventFields.toEvent(true)
+	if (err != nil) {
+		return err
+	}
+
+	account, err := addEventTarget.getWritableAccount()
+	if (err != nil) {
+		return err
+	}
+
+	calendarId, err := addEventTarget.calendarId(account)
+	if (err != nil) {
+		return err
+	}
+
+	created, err := account.InsertEvent(calendarId, event, *addEventTarget.sendUpdates.Value)
+	if (err != nil) {
+		return err
+	}
+
+	fmt.Printf("event '%s' was added: %s\n", created.Id, created.HtmlLink)
+	return nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var authRefreshCmd = &cobra.Command{
+	Use:   "refresh [account name]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Re-authorize account",
+	Long: `Re-authorize account whose refresh token was revoked or expired. Only the token in the keyring is replaced,
+the account config, including whitelisted and blacklisted calendars, is kept. For example:
+
+figoro auth refresh work`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := refreshAccount(args[0])
+		if (err != nil) {
+			showError(fmt.Sprintf("failed to re-authorize account '%s'", args[0]), err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	authCmd.AddCommand(authRefreshCmd)
+
+	addAuthFlags(authRefreshCmd)
+}
+
+func refreshAccount(accName string) error {
+	_, err := getAccountFromConfig(accName)
+	if (err != nil) {
+		return err
+	}
+
+	_, err = reauthorizeAccount(accName, &logger)
+	if (err != nil) {
+		return err
+	}
+
+	fmt.Printf("account '%s' was re-authorized\n", accName)
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/eventsrender"
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/EugeneShtoka/figoro/lib/gaseed"
+	"github.com/spf13/cobra"
+)
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show authorization status of accounts",
+	Long: `Check with Google that the token of every account is still valid and show when it expires.
+Expired access tokens are refreshed on the way. figoro exits with code 1 when any account needs attention.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := showAuthStatus(cmd.Context(), os.Stdout)
+		if (err != nil) {
+			showError("failed to check authorization of accounts", err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	authCmd.AddCommand(authStatusCmd)
+}
+
+func showAuthStatus(ctx context.Context, w io.Writer) error {
+	// accounts are not initialized, broken tokens are reported in the table instead
+	accounts, err := readAccountsFromConfig()
+	if (err != nil) {
+		return fmt.Errorf("failed to read accounts from config: %w", err)
+	}
+	if (len(accounts) == 0) {
+		fmt.Fprintln(w, "no accounts configured")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ACCOUNT\tSTATUS\tEXPIRES\tSCOPES")
+	for _, account := range accounts {
+		info, err := gaccount.InspectToken(ctx, serviceName, account.Name)
+		if (err != nil) {
+			exitCode = 1
+			status := fmt.Sprintf("error: %v", err)
+			if (errors.Is(err, gaseed.ErrInvalidGrant)) {
+				status = fmt.Sprintf("revoked, run 'figoro auth refresh %s'", account.Name)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t\t\n", account.Name, status)
+			continue
+		}
+
+		scopes := make([]string, len(info.Scopes))
+		for i, scope := range info.Scopes {
+			scopes[i] = strings.TrimPrefix(scope, "https://www.googleapis.com/auth/")
+		}
+		expires := fmt.Sprintf("%s (in %s)", info.Expiry.Local().Format("2006-01-02 15:04"), eventsrender.ShortDuration(time.Until(info.Expiry)))
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", account.Name, "valid", expires, strings.Join(scopes, ","))
+	}
+	return writer.Flush()
+}
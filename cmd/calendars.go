@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/spf13/cobra"
+)
+
+var calendarsCmd = &cobra.Command{
+	Use:   "calendars",
+	Short: "Manage calendars of accounts",
+	Long: `Manage which calendars of an account are shown. Requires a subcommand [include, exclude, reset].
+
+Calendars are given by ID or summary, globs (*, ?, [...]) and /regular expressions/ match several at once.
+When any calendar is included, only included calendars are shown, otherwise all but excluded ones.`,
+}
+
+func init() {
+	rootCmd.AddCommand(calendarsCmd)
+}
+
+// findCalendars resolves calendar patterns of the account to calendar IDs
+func findCalendars(account *gaccount.GAccount, patterns []string) ([]string, error) {
+	ids := make([]string, 0)
+	for _, pattern := range patterns {
+		found, err := account.FindCalendars(pattern)
+		if (err != nil) {
+			return nil, err
+		}
+		for _, id := range found {
+			if (!slices.Contains(ids, id)) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// changeCalendars applies the change to the calendar lists of the account and saves them to config
+func changeCalendars(accName string, patterns []string, change func(calendars *gaccount.GCalendars, ids []string)) error {
+	account, err := getAccountFromConfig(accName)
+	if (err != nil) {
+		return err
+	}
+
+	var ids []string
+	if (len(patterns) > 0) {
+		ids, err = findCalendars(account, patterns)
+		if (err != nil) {
+			return err
+		}
+	}
+
+	change(&account.Calendars, ids)
+	err = updateAccountInConfig(account)
+	if (err != nil) {
+		return err
+	}
+
+	fmt.Printf("account '%s' shows calendars:\n", account.Name)
+	for _, id := range account.ResolveCalendars() {
+		fmt.Printf("\t%s\n", describeCalendarID(account, id))
+	}
+	return nil
+}
+
+// describeCalendar names the calendar together with its ID
+func describeCalendar(cal *gaccount.Calendar) string {
+	if (cal.Name() == cal.ID) {
+		return cal.ID
+	}
+	return fmt.Sprintf("%s (%s)", cal.Name(), cal.ID)
+}
+
+func describeCalendarID(account *gaccount.GAccount, id string) string {
+	cal := account.Calendars.Find(id)
+	if (cal == nil) {
+		return id
+	}
+	return describeCalendar(cal)
+}
+
+// addCalendars appends ids missing from list
+func addCalendars(list []string, ids []string) []string {
+	for _, id := range ids {
+		if (!slices.Contains(list, id)) {
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// removeCalendars drops ids from list
+func removeCalendars(list []string, ids []string) []string {
+	return slices.DeleteFunc(list, func(id string) bool { return slices.Contains(ids, id) })
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/spf13/cobra"
+)
+
+var calendarsIncludeCmd = &cobra.Command{
+	Use:   "include [account name] [calendar]...",
+	Args:  cobra.MinimumNArgs(2),
+	Short: "Show only included calendars",
+	Long: `Add calendars to the whitelist of the account, removing them from the blacklist. For example:
+
+figoro calendars include work primary 'Team*'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := changeCalendars(args[0], args[1:], func(calendars *gaccount.GCalendars, ids []string) {
+			calendars.WhiteList = addCalendars(calendars.WhiteList, ids)
+			calendars.BlackList = removeCalendars(calendars.BlackList, ids)
+		})
+		if (err != nil) {
+			showError(fmt.Sprintf("failed to include calendars of account '%s'", args[0]), err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	calendarsCmd.AddCommand(calendarsIncludeCmd)
+}
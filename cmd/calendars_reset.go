@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/spf13/cobra"
+)
+
+var calendarsResetCmd = &cobra.Command{
+	Use:   "reset [account name] [calendar]...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Forget calendars included or excluded",
+	Long: `Remove calendars from both the whitelist and the blacklist of the account,
+without calendars both lists are cleared and all calendars are shown. For example:
+
+figoro calendars reset work`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := resetCalendars(args[0], args[1:])
+		if (err != nil) {
+			showError(fmt.Sprintf("failed to reset calendars of account '%s'", args[0]), err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	calendarsCmd.AddCommand(calendarsResetCmd)
+}
+
+func resetCalendars(accName string, patterns []string) error {
+	return changeCalendars(accName, patterns, func(calendars *gaccount.GCalendars, ids []string) {
+		if (len(patterns) == 0) {
+			calendars.WhiteList = nil
+			calendars.BlackList = nil
+			return
+		}
+		calendars.WhiteList = removeCalendars(calendars.WhiteList, ids)
+		calendars.BlackList = removeCalendars(calendars.BlackList, ids)
+	})
+}
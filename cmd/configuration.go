@@ -3,15 +3,40 @@ package cmd
 import (
 	"fmt"
 	"iter"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
 
 	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 	"spheric.cloud/xiter"
 )
 
-func getAccountsFromConfig() ([]gaccount.GAccount) {
+// readAccountsFromConfig decodes accounts without initializing them
+func readAccountsFromConfig() ([]gaccount.GAccount, error) {
 	var accounts []gaccount.GAccount
-	err := viper.UnmarshalKey(accountsConfigKey, &accounts)
+	err := viper.UnmarshalKey(accountsConfigKey, &accounts, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+		legacyCalendarHook,
+	)))
+	return accounts, err
+}
+
+// legacyCalendarHook reads calendars stored as bare IDs by older versions, their metadata is filled by sync calendars
+func legacyCalendarHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if (from.Kind() != reflect.String || to != reflect.TypeOf(gaccount.Calendar{})) {
+		return data, nil
+	}
+	return gaccount.Calendar{ ID: data.(string) }, nil
+}
+
+func getAccountsFromConfig() ([]gaccount.GAccount) {
+	accounts, err := readAccountsFromConfig()
 	if (err != nil) {
 		showError("failed to read accounts from config:", err)
 	}
@@ -26,8 +51,60 @@ func getAccountsFromConfig() ([]gaccount.GAccount) {
 	return accounts
 }
 
+func getAccountFromConfig(accName string) (*gaccount.GAccount, error) {
+	accounts := getAccountsFromConfig()
+	index := slices.IndexFunc(accounts, func(acc gaccount.GAccount) bool { return acc.Name == accName })
+	if (index < 0) {
+		return nil, fmt.Errorf("account '%s' does not exist in config", accName)
+	}
+	return &accounts[index], nil
+}
+
 func getAccountsIterFromConfig() (iter.Seq[gaccount.GAccount]) {
 	tempAccounts := getAccountsFromConfig()
 	accounts := xiter.OfSlice(tempAccounts)
 	return accounts
-}
\ No newline at end of file
+}
+// updateAccountInConfig replaces the account with the same name in config
+func updateAccountInConfig(account *gaccount.GAccount) error {
+	accounts, err := readAccountsFromConfig()
+	if (err != nil) {
+		return fmt.Errorf("failed to read accounts from config: %w", err)
+	}
+
+	index := slices.IndexFunc(accounts, func(acc gaccount.GAccount) bool { return acc.Name == account.Name })
+	if (index < 0) {
+		return fmt.Errorf("account '%s' does not exist in config", account.Name)
+	}
+	accounts[index] = *account
+
+	viper.Set(accountsConfigKey, accounts)
+	return writeConfig()
+}
+
+// writeConfig writes config to a temporary file next to it and renames it over the config,
+// so that an interrupted write never leaves a truncated config behind
+func writeConfig() error {
+	path := viper.ConfigFileUsed()
+	if (path == "") {
+		return fmt.Errorf("no config file is used")
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".*" + filepath.Base(path))
+	if (err != nil) {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	tempPath := temp.Name()
+	temp.Close()
+	defer os.Remove(tempPath)
+
+	err = viper.WriteConfigAs(tempPath)
+	if (err != nil) {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	err = os.Rename(tempPath, path)
+	if (err != nil) {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
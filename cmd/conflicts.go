@@ -0,0 +1,131 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/conflicts"
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/spf13/cobra"
+)
+
+var (
+	conflictsQuery				*eventsQuery
+	conflictsIgnoreDeclined		*managedflag.BoolFlag
+	conflictsIgnoreTransparent	*managedflag.BoolFlag
+	conflictsIgnoreAllDay		*managedflag.BoolFlag
+	conflictsAcrossAccounts		*managedflag.BoolFlag
+	conflictsOutput				*managedflag.StrFlag
+
+	conflictTimeLayout = "Mon 2006-01-02 15:04"
+	timeOfDayLayout = "15:04"
+)
+
+var conflictsCmd = &cobra.Command{
+	Use:   "conflicts",
+	Args:  cobra.NoArgs,
+	Short: "Find overlapping events",
+	Long: `Find overlapping events and double bookings in calendars of all accounts. For example:
+
+figoro conflicts --to 2024-06-01 --across-accounts
+
+figoro conflicts --in 2w
+
+figoro conflicts --ignore-all-day=false --ignore-transparent=false
+
+` + timeFlagsHelp,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := showConflicts(cmd.Context(), os.Stdout)
+		if (err != nil) {
+			showError("failed to find conflicts", err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(conflictsCmd)
+
+	conflictsQuery = newEventsQuery(conflictsCmd)
+	conflictsIgnoreDeclined = managedflag.NewBool(conflictsCmd, "ignore-declined", true, "ignore events you declined")
+	conflictsIgnoreTransparent = managedflag.NewBool(conflictsCmd, "ignore-transparent", true, "ignore events marked as free")
+	conflictsIgnoreAllDay = managedflag.NewBool(conflictsCmd, "ignore-all-day", true, "ignore all-day events")
+	conflictsAcrossAccounts = managedflag.NewBool(conflictsCmd, "across-accounts", false, "report only conflicts between events of different accounts")
+	conflictsOutput = managedflag.NewStrP(conflictsCmd, "output", "o", "text", "output format [text, json]")
+}
+
+func describeConflictEvent(event *figevent.Event) string {
+	return fmt.Sprintf("'%s' (%s/%s)", event.Summary, event.Account, event.CalendarName())
+}
+
+func renderConflicts(w io.Writer, format string, found []conflicts.Conflict) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(found)
+	case "text":
+		if (len(found) == 0) {
+			_, err := fmt.Fprintln(w, "no conflicts found")
+			return err
+		}
+		for _, conflict := range found {
+			_, err := fmt.Fprintf(w, "%s - %s  %s overlaps %s\n",
+				conflict.Overlap.Start.Local().Format(conflictTimeLayout),
+				conflict.Overlap.End.Local().Format(timeOfDayLayout),
+				describeConflictEvent(conflict.First),
+				describeConflictEvent(conflict.Second))
+			if (err != nil) {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format '%s', expected one of [text, json]", format)
+}
+
+func showConflicts(ctx context.Context, w io.Writer) error {
+	// overlaps are between instances, so recurring events have to be expanded
+	filter, err := conflictsQuery.filter()
+	if (err != nil) {
+		return err
+	}
+	filter = filter.ShowSingle().OrderBy("startTime")
+	events, warnings, err := conflictsQuery.events(ctx, filter)
+	if (err != nil) {
+		return err
+	}
+	reportWarnings(warnings)
+
+	found, err := conflicts.Find(events, &conflicts.Options{
+		IgnoreDeclined: *conflictsIgnoreDeclined.Value,
+		IgnoreTransparent: *conflictsIgnoreTransparent.Value,
+		IgnoreAllDay: *conflictsIgnoreAllDay.Value,
+		AcrossAccounts: *conflictsAcrossAccounts.Value,
+		Location: time.Local,
+	})
+	if (err != nil) {
+		return err
+	}
+
+	return renderConflicts(w, *conflictsOutput.Value, found)
+}
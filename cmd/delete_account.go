@@ -17,22 +17,33 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"slices"
 
 	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/EugeneShtoka/figoro/lib/gaseed"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
 	"github.com/EugeneShtoka/figoro/lib/typedkeyring"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var (
+	deleteKeepGrant		*managedflag.BoolFlag
+
+	revokePermissionsURL = "https://myaccount.google.com/permissions"
+)
+
 var deleteAccountCmd = &cobra.Command{
 	Use:   "account [account name]",
 	Args:  cobra.ExactArgs(1),
 	Short: "Delete account",
-	Long: "Delete account. Requires account name to delete",
+	Long: `Delete account. Requires account name to delete. The access granted to figoro is revoked at Google
+unless --keep-grant is set.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := deleteAccountFromConfig(args[0])
+		err := deleteAccountFromConfig(cmd.Context(), args[0], *deleteKeepGrant.Value)
 		if (err != nil) {
 			showError(fmt.Sprintf("failed to delete account '%s'", args[0]), err)
 			cmd.Usage()
@@ -42,22 +53,49 @@ var deleteAccountCmd = &cobra.Command{
 
 func init() {
 	deleteCmd.AddCommand(deleteAccountCmd)
+
+	deleteKeepGrant = managedflag.NewBool(deleteAccountCmd, "keep-grant", false, "keep the access granted at Google, only forget the token locally")
 }
 
-func deleteAccountFromConfig(accName string) error {
+// deleteAccountFromConfig removes the account from config and its token from the keyring, restoring config
+// when the keyring fails. The grant is revoked last, once nothing local depends on the token
+func deleteAccountFromConfig(ctx context.Context, accName string, keepGrant bool) error {
 	accounts := getAccountsFromConfig()
 
 	predicate := func(acc gaccount.GAccount) bool { return acc.Name == accName }
 	if (!slices.ContainsFunc(accounts, predicate)) {
 		return fmt.Errorf("account '%s' does not exist in config", accName)	
 	}
-	accounts = slices.DeleteFunc(accounts, predicate)
+	remaining := slices.DeleteFunc(slices.Clone(accounts), predicate)
+
+	seed, seedErr := typedkeyring.New[gaseed.GASeed](serviceName).Load(accName)
+	if (seedErr != nil && !keepGrant) {
+		logger.Warn().Err(seedErr).Str("account", accName).Msg("token is not available, grant cannot be revoked")
+	}
 
-	viper.Set(accountsConfigKey, accounts)
-	err := viper.WriteConfig()
+	viper.Set(accountsConfigKey, remaining)
+	err := writeConfig()
 	if err != nil {
 		return fmt.Errorf("failed to delete account '%s' from config: %w", accName, err)
 	}
+
 	keyring := typedkeyring.New[any](serviceName)
-	return keyring.Delete(accName)
+	err = keyring.Delete(accName)
+	if (err != nil && !errors.Is(err, typedkeyring.ErrNotFound)) {
+		viper.Set(accountsConfigKey, accounts)
+		restoreErr := writeConfig()
+		if (restoreErr != nil) {
+			return fmt.Errorf("%w, and restoring config failed: %w", err, restoreErr)
+		}
+		return fmt.Errorf("%w, account was kept in config", err)
+	}
+
+	if (keepGrant || seedErr != nil) {
+		return nil
+	}
+	err = seed.Revoke(ctx)
+	if (err != nil) {
+		return fmt.Errorf("account was deleted, but its grant is still active, remove figoro at %s: %w", revokePermissionsURL, err)
+	}
+	return nil
 }
@@ -0,0 +1,65 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var deleteEventTarget *eventTarget
+
+var deleteEventCmd = &cobra.Command{
+	Use:   "event [event id]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Delete event",
+	Long: "Delete event. Requires event id to delete",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := deleteEvent(args[0])
+		if (err != nil) {
+			showError(fmt.Sprintf("failed to delete event '%s'", args[0]), err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	deleteCmd.AddCommand(deleteEventCmd)
+
+	deleteEventTarget = newEventTarget(deleteEventCmd, true)
+}
+
+func deleteEvent(eventId string) error {
+	account, err := deleteEventTarget.getWritableAccount()
+	if (err != nil) {
+		return err
+	}
+
+	calendarId, err := deleteEventTarget.calendarId(account)
+	if (err != nil) {
+		return err
+	}
+
+	err = account.DeleteEvent(calendarId, eventId, *deleteEventTarget.sendUpdates.Value)
+	if (err != nil) {
+		return err
+	}
+
+	fmt.Printf("event '%s' was deleted\n", eventId)
+	return nil
+}
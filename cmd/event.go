@@ -0,0 +1,246 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/calendar/v3"
+)
+
+var (
+	eventDateTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"}
+)
+
+// eventTarget holds the flags selecting the account and calendar an event command works on
+type eventTarget struct {
+	account			*managedflag.StrFlag
+	calendar		*managedflag.StrFlag
+	sendUpdates		*managedflag.StrFlag
+}
+
+// eventFlags holds the flags describing event fields for add and update commands
+type eventFlags struct {
+	title			*managedflag.StrFlag
+	start			*managedflag.StrFlag
+	end				*managedflag.StrFlag
+	allDay			*managedflag.BoolFlag
+	timeZone		*managedflag.StrFlag
+	location		*managedflag.StrFlag
+	description		*managedflag.StrFlag
+	attendees		*managedflag.StrSliceFlag
+	recurrence		*managedflag.StrSliceFlag
+}
+
+// newEventTarget adds account and calendar flags, notify adds a flag controlling notifications of attendees
+func newEventTarget(cmd *cobra.Command, notify bool) *eventTarget {
+	target := &eventTarget{
+		account: managedflag.NewStrP(cmd, "account", "a", "", "name of the account owning the calendar"),
+		calendar: managedflag.NewStrP(cmd, "calendar", "c", "primary", "calendar id or name"),
+	}
+	if (notify) {
+		target.sendUpdates = managedflag.NewStr(cmd, "send-updates", "none", "notify attendees [all, externalOnly, none]")
+	}
+	cmd.MarkFlagRequired("account")
+	return target
+}
+
+func newEventFlags(cmd *cobra.Command) *eventFlags {
+	return &eventFlags{
+		title: managedflag.NewStrP(cmd, "title", "t", "", "event title"),
+		start: managedflag.NewStr(cmd, "start", "", "start time (RFC3339 or '2006-01-02 15:04'), or date for all-day events"),
+		end: managedflag.NewStr(cmd, "end", "", "end time, or date (exclusive) for all-day events (default start + 1h or next day, updates keep the duration)"),
+		allDay: managedflag.NewBool(cmd, "all-day", false, "create an all-day event, start and end are dates"),
+		timeZone: managedflag.NewStr(cmd, "timezone", "", "IANA time zone of start and end, required for recurring events"),
+		location: managedflag.NewStr(cmd, "location", "", "event location"),
+		description: managedflag.NewStr(cmd, "description", "", "event description"),
+		attendees: managedflag.NewStrSlice(cmd, "attendees", nil, "emails of attendees"),
+		recurrence: managedflag.NewStrSlice(cmd, "recurrence", nil, "RRULE, EXRULE, RDATE or EXDATE lines, e.g. 'RRULE:FREQ=WEEKLY;COUNT=5'"),
+	}
+}
+
+// calendarId resolves the calendar flag, which can be a calendar name, to the calendar ID of the account
+func (t *eventTarget) calendarId(account *gaccount.GAccount) (string, error) {
+	return account.ResolveCalendar(*t.calendar.Value)
+}
+
+// getWritableAccount returns the account, offering to re-authorize it if it was added with read-only access
+func (t *eventTarget) getWritableAccount() (*gaccount.GAccount, error) {
+	account, err := getAccountFromConfig(*t.account.Value)
+	if (err != nil) {
+		return nil, err
+	}
+
+	writable, err := gaccount.HasScope(rootCmd.Context(), serviceName, account.Name, calendar.CalendarEventsScope)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to check permissions of account '%s': %w", account.Name, err)
+	}
+	if (writable) {
+		return account, nil
+	}
+
+	prompt := promptui.Prompt{
+		Label:     fmt.Sprintf("Account '%s' has read-only access. Re-authorize it to allow editing events", account.Name),
+		IsConfirm: true,
+	}
+	_, err = prompt.Run()
+	if (err != nil) {
+		return nil, fmt.Errorf("account '%s' has no permission to edit events", account.Name)
+	}
+
+	seed, err := reauthorizeAccount(account.Name, &logger)
+	if (err != nil) {
+		return nil, err
+	}
+	if (seed.GrantedScopes != nil && !slices.Contains(seed.GrantedScopes, calendar.CalendarEventsScope)) {
+		return nil, fmt.Errorf("permission to edit events was not granted to account '%s'", account.Name)
+	}
+
+	err = account.Init(serviceName)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to initialize account '%s': %w", account.Name, err)
+	}
+	return account, nil
+}
+
+func parseEventDateTime(name string, value string, allDay bool, timeZone string) (*calendar.EventDateTime, time.Time, error) {
+	if (allDay) {
+		date, err := time.Parse(time.DateOnly, value)
+		if (err != nil) {
+			return nil, time.Time{}, fmt.Errorf("invalid %s date '%s', expected YYYY-MM-DD", name, value)
+		}
+		return &calendar.EventDateTime{ Date: date.Format(time.DateOnly) }, date, nil
+	}
+
+	location := time.Local
+	if (timeZone != "") {
+		var err error
+		location, err = time.LoadLocation(timeZone)
+		if (err != nil) {
+			return nil, time.Time{}, fmt.Errorf("invalid timezone '%s': %w", timeZone, err)
+		}
+	}
+
+	for _, layout := range eventDateTimeLayouts {
+		dateTime, err := time.ParseInLocation(layout, value, location)
+		if (err == nil) {
+			return &calendar.EventDateTime{ DateTime: dateTime.Format(time.RFC3339), TimeZone: timeZone }, dateTime, nil
+		}
+	}
+	return nil, time.Time{}, fmt.Errorf("invalid %s time '%s', expected RFC3339 or '2006-01-02 15:04'", name, value)
+}
+
+func defaultEventEnd(start time.Time, allDay bool, timeZone string) *calendar.EventDateTime {
+	if (allDay) {
+		return &calendar.EventDateTime{ Date: start.AddDate(0, 0, 1).Format(time.DateOnly) }
+	}
+	return &calendar.EventDateTime{ DateTime: start.Add(time.Hour).Format(time.RFC3339), TimeZone: timeZone }
+}
+
+// toEvent builds an event out of the flags which were set, so it can be used both for insert and patch.
+// A new start without an end gets the default end only for new events, updates have to keep the duration
+func (f *eventFlags) toEvent(requireStart bool) (*calendar.Event, error) {
+	event := &calendar.Event{}
+
+	if (f.title.IsChanged()) {
+		event.Summary = *f.title.Value
+	}
+	if (f.location.IsChanged()) {
+		event.Location = *f.location.Value
+	}
+	if (f.description.IsChanged()) {
+		event.Description = *f.description.Value
+	}
+	if (f.attendees.IsChanged()) {
+		for _, email := range *f.attendees.Value {
+			event.Attendees = append(event.Attendees, &calendar.EventAttendee{ Email: email })
+		}
+	}
+	if (f.recurrence.IsChanged()) {
+		event.Recurrence = *f.recurrence.Value
+	}
+
+	timeZone := *f.timeZone.Value
+	if (len(event.Recurrence) > 0 && !*f.allDay.Value && timeZone == "") {
+		return nil, fmt.Errorf("--timezone is required for recurring events")
+	}
+
+	if (!f.start.IsChanged()) {
+		if (requireStart) {
+			return nil, fmt.Errorf("--start is required")
+		}
+		if (f.end.IsChanged()) {
+			end, _, err := parseEventDateTime("end", *f.end.Value, *f.allDay.Value, timeZone)
+			if (err != nil) {
+				return nil, err
+			}
+			event.End = end
+		}
+		return event, nil
+	}
+
+	start, startTime, err := parseEventDateTime("start", *f.start.Value, *f.allDay.Value, timeZone)
+	if (err != nil) {
+		return nil, err
+	}
+	event.Start = start
+
+	if (f.end.IsChanged()) {
+		event.End, _, err = parseEventDateTime("end", *f.end.Value, *f.allDay.Value, timeZone)
+		if (err != nil) {
+			return nil, err
+		}
+	} else if (requireStart) {
+		event.End = defaultEventEnd(startTime, *f.allDay.Value, timeZone)
+	}
+
+	return event, nil
+}
+
+// shiftedEnd moves the end of the current event along with the new start, so that its duration is kept.
+// When the event changes between timed and all-day, the default end is used
+func shiftedEnd(current *calendar.Event, start *calendar.EventDateTime) (*calendar.EventDateTime, error) {
+	startTime, err := figevent.ParseTime(start, time.UTC)
+	if (err != nil) {
+		return nil, err
+	}
+	allDay := (start.DateTime == "")
+	if (current.Start == nil || current.End == nil || allDay != (current.Start.DateTime == "")) {
+		return defaultEventEnd(startTime, allDay, start.TimeZone), nil
+	}
+
+	currentStart, err := figevent.ParseTime(current.Start, time.UTC)
+	if (err != nil) {
+		return nil, fmt.Errorf("invalid start of event '%s': %w", current.Id, err)
+	}
+	currentEnd, err := figevent.ParseTime(current.End, time.UTC)
+	if (err != nil) {
+		return nil, fmt.Errorf("invalid end of event '%s': %w", current.Id, err)
+	}
+	if (allDay) {
+		days := int(currentEnd.Sub(currentStart) / (24 * time.Hour))
+		return &calendar.EventDateTime{ Date: startTime.AddDate(0, 0, days).Format(time.DateOnly) }, nil
+	}
+	return &calendar.EventDateTime{ DateTime: startTime.Add(currentEnd.Sub(currentStart)).Format(time.RFC3339), TimeZone: start.TimeZone }, nil
+}
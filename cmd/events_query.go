@@ -0,0 +1,242 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/combaccount"
+	"github.com/EugeneShtoka/figoro/lib/eventsfilter"
+	"github.com/EugeneShtoka/figoro/lib/eventsrender"
+	"github.com/EugeneShtoka/figoro/lib/eventstore"
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	cacheDirConfigKey = "cache.dir"
+)
+
+// eventsQuery holds the flags selecting events, shared by the commands which read events of all accounts
+type eventsQuery struct {
+	minEndTime		*managedflag.StrFlag
+	maxStartTime	*managedflag.StrFlag
+	period			*timeRangeFlags
+	eventTypes		*managedflag.StrFlag
+	orderBy			*managedflag.StrFlag
+
+	maxResults		*managedflag.Int64Flag
+
+	single			*managedflag.BoolFlag
+	deleted			*managedflag.BoolFlag
+
+	offline			*managedflag.BoolFlag
+	noCache			*managedflag.BoolFlag
+	maxAge			*managedflag.DurationFlag
+
+	partial			*managedflag.BoolFlag
+
+	selection		*calendarSelection
+}
+
+func newEventsQuery(cmd *cobra.Command) *eventsQuery {
+	return &eventsQuery{
+		minEndTime: managedflag.NewStr(cmd, "minEndTime", "", "list events with end times later than (default now)"),
+		maxStartTime: managedflag.NewStr(cmd, "maxStartTime", "", "list events with start times earlier than"),
+		period: newTimeRangeFlags(cmd, "list events ending after (default now)", "list events starting before"),
+		eventTypes: managedflag.NewStr(cmd, "eventTypes", "", "list events with specified event types"),
+		orderBy: managedflag.NewStr(cmd, "orderBy", "", "list events with specified order"),
+
+		maxResults: managedflag.NewInt64(cmd, "maxResults", 0, "max results per account"),
+
+		single: managedflag.NewBool(cmd, "single", false, "list events for all accounts"),
+		deleted: managedflag.NewBool(cmd, "deleted", false, "list events for all accounts"),
+
+		offline: managedflag.NewBool(cmd, "offline", false, "list events from local cache only, without contacting Google"),
+		noCache: managedflag.NewBool(cmd, "no-cache", false, "query Google directly, bypassing the local cache"),
+		maxAge: managedflag.NewDuration(cmd, "max-age", 0, "use cached events without syncing if they are younger than this (e.g. 15m)"),
+
+		partial: managedflag.NewBool(cmd, "partial", false, "show events of healthy accounts when others fail, reporting failures as warnings (default true for terminal)"),
+
+		selection: newCalendarSelection(cmd),
+	}
+}
+
+// filter builds the filter of the flags, times are normalized to RFC3339 before they reach the filter
+func (q *eventsQuery) filter() (*eventsfilter.EventsFilter, error) {
+	parser, err := newTimeParser()
+	if (err != nil) {
+		return nil, err
+	}
+	if (q.period.isSet() && (q.minEndTime.IsChanged() || q.maxStartTime.IsChanged())) {
+		return nil, fmt.Errorf("--minEndTime and --maxStartTime cannot be used with --from, --to, --range or --in")
+	}
+
+	from, to, err := q.period.period(parser)
+	if (err != nil) {
+		return nil, err
+	}
+	if (q.minEndTime.IsChanged()) {
+		minEndTime, err := parseTimeFlag(parser, "minEndTime", *q.minEndTime.Value, parser.Now)
+		if (err != nil) {
+			return nil, err
+		}
+		from = &minEndTime
+	}
+	if (q.maxStartTime.IsChanged()) {
+		maxStartTime, err := parseEndFlag(parser, "maxStartTime", *q.maxStartTime.Value, parser.Now)
+		if (err != nil) {
+			return nil, err
+		}
+		to = &maxStartTime
+	}
+
+	if (from == nil) {
+		from = &parser.Now
+	}
+	filter := eventsfilter.New().MinEndTime(from.Format(time.RFC3339))
+
+	if (to != nil) {
+		filter = filter.MaxStartTime(to.Format(time.RFC3339))
+	}
+
+	if (q.eventTypes.IsChanged()) {
+		filter = filter.EventTypes(*q.eventTypes.Value)
+	}
+
+	if (q.orderBy.IsChanged()) {
+		filter = filter.OrderBy(*q.orderBy.Value)
+	}
+
+	if (q.maxResults.IsChanged()) {
+		filter = filter.MaxResults(*q.maxResults.Value)
+	}
+
+	if (q.single.IsChanged() && *q.single.Value) {
+		filter = filter.ShowSingle()
+	}
+
+	if (q.deleted.IsChanged() && *q.deleted.Value) {
+		filter = filter.ShowDeleted()
+	}
+
+	return filter, nil
+}
+
+func getEventStore() (*eventstore.Store, error) {
+	dir := viper.GetString(cacheDirConfigKey)
+	if (dir == "") {
+		var err error
+		dir, err = eventstore.DefaultDir(serviceName)
+		if (err != nil) {
+			return nil, err
+		}
+	}
+	return eventstore.New(dir), nil
+}
+
+// isPartial tells whether failed accounts and calendars are reported as warnings instead of failing the command
+func (q *eventsQuery) isPartial() bool {
+	if (q.partial.IsChanged()) {
+		return *q.partial.Value
+	}
+	return isTerminal()
+}
+
+// events reads events of the selected accounts and calendars, from the local cache unless --no-cache is set.
+// In partial mode the sources which failed are returned as warnings next to the events of the others
+func (q *eventsQuery) events(ctx context.Context, filter *eventsfilter.EventsFilter) ([]*figevent.Event, []*combaccount.SourceError, error) {
+	accounts := getAccountsFromConfig()
+	if (!*q.offline.Value) {
+		refreshStaleCalendars(ctx, accounts)
+	}
+	accounts, err := q.selection.apply(accounts)
+	if (err != nil) {
+		return nil, nil, err
+	}
+	account, err := combaccount.New(serviceName, accounts)
+	if (err != nil) {
+		return nil, nil, fmt.Errorf("failed to initialize accounts: %v: %v", accounts, err)
+	}
+
+	var events []*figevent.Event
+	if (*q.noCache.Value) {
+		if (*q.offline.Value) {
+			return nil, nil, fmt.Errorf("--offline and --no-cache cannot be used together")
+		}
+		events, err = account.Events(ctx, filter)
+	} else {
+		var store *eventstore.Store
+		store, err = getEventStore()
+		if (err != nil) {
+			return nil, nil, err
+		}
+		events, err = account.CachedEvents(ctx, filter, &combaccount.CacheOptions{
+			Store: store,
+			MaxAge: *q.maxAge.Value,
+			Offline: *q.offline.Value,
+		})
+	}
+	if (err != nil) {
+		warnings := combaccount.SourceErrors(err)
+		if (q.isPartial() && warnings != nil) {
+			return events, warnings, nil
+		}
+		return nil, nil, fmt.Errorf("failed to retrieve events for accounts: %v: %v", accounts, err)
+	}
+
+	return events, nil, nil
+}
+
+// reportWarnings prints failed sources to stderr, grouped by account, and makes figoro exit with exitPartial
+func reportWarnings(warnings []*combaccount.SourceError) {
+	if (len(warnings) == 0) {
+		return
+	}
+	exitCode = exitPartial
+
+	sorted := slices.Clone(warnings)
+	slices.SortStableFunc(sorted, func(a, b *combaccount.SourceError) int { return strings.Compare(a.Account, b.Account) })
+
+	fmt.Fprintf(os.Stderr, "warning: events of %d source(s) are missing:\n", len(sorted))
+	for i, warning := range sorted {
+		if (i == 0 || sorted[i - 1].Account != warning.Account) {
+			fmt.Fprintf(os.Stderr, "  account '%s':\n", warning.Account)
+		}
+		logger.Warn().Err(warning.Err).Str("account", warning.Account).Str("calendar", warning.CalendarID).Msg("source failed")
+		fmt.Fprintf(os.Stderr, "    calendar '%s': %s\n", warning.CalendarID, warning.Reason())
+	}
+}
+
+func toRenderWarnings(warnings []*combaccount.SourceError) []eventsrender.Warning {
+	renderWarnings := make([]eventsrender.Warning, len(warnings))
+	for i, warning := range warnings {
+		renderWarnings[i] = eventsrender.Warning{
+			Account: warning.Account,
+			Calendar: warning.CalendarID,
+			Error: warning.Reason(),
+		}
+	}
+	return renderWarnings
+}
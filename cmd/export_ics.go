@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/EugeneShtoka/figoro/lib/ics"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportIcsQuery	*eventsQuery
+	exportIcsFile	*managedflag.StrFlag
+	exportIcsName	*managedflag.StrFlag
+)
+
+var exportIcsCmd = &cobra.Command{
+	Use:   "ics",
+	Args:  cobra.NoArgs,
+	Short: "Export events as iCalendar file",
+	Long: `Export events of all accounts as one iCalendar (RFC 5545) file. Recurring events are exported
+with their recurrence rules unless --single is set. For example:
+
+figoro export ics -f merged.ics --to 2025-01-01
+
+figoro export ics -f month.ics --range next-month
+
+` + timeFlagsHelp,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := exportIcs(cmd.Context())
+		if (err != nil) {
+			showError("failed to export events", err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportIcsCmd)
+
+	exportIcsQuery = newEventsQuery(exportIcsCmd)
+	exportIcsFile = managedflag.NewStrP(exportIcsCmd, "file", "f", "", "path of the file to write (default stdout)")
+	exportIcsName = managedflag.NewStr(exportIcsCmd, "name", serviceName, "calendar name shown by calendar applications")
+}
+
+func exportIcs(ctx context.Context) error {
+	filter, err := exportIcsQuery.filter()
+	if (err != nil) {
+		return err
+	}
+	// cancelled occurrences become exceptions of their series
+	filter = filter.ShowCancelledOccurrences()
+	events, warnings, err := exportIcsQuery.events(ctx, filter)
+	if (err != nil) {
+		return err
+	}
+	reportWarnings(warnings)
+
+	var w io.Writer = os.Stdout
+	if (exportIcsFile.IsChanged()) {
+		file, err := os.Create(*exportIcsFile.Value)
+		if (err != nil) {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	encoder := ics.NewEncoder(w)
+	encoder.Name = *exportIcsName.Value
+	err = encoder.Encode(events)
+	if (err != nil) {
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	return nil
+}
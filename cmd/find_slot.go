@@ -0,0 +1,247 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/combaccount"
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/EugeneShtoka/figoro/lib/interval"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/EugeneShtoka/figoro/lib/timeparse"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	slotDuration	*managedflag.DurationFlag
+	slotDays		*managedflag.Int64Flag
+	slotFrom		*managedflag.StrFlag
+	slotWeekends	*managedflag.BoolFlag
+	slotWith		*managedflag.StrSliceFlag
+	slotVia			*managedflag.StrFlag
+	slotOutput		*managedflag.StrFlag
+
+	workingHoursConfigKey = "slots.workingHours"
+	slotTimeZoneConfigKey = "slots.timezone"
+	slotBufferConfigKey = "slots.buffer"
+	slotAllDayConfigKey = "slots.allDay"
+
+	workingHoursLayout = "15:04"
+)
+
+var findSlotCmd = &cobra.Command{
+	Use:   "find-slot",
+	Args:  cobra.NoArgs,
+	Short: "Find free slots for a meeting",
+	Long: `Find free slots for a meeting within working hours, considering events of all accounts
+and optionally free/busy of other people. For example:
+
+figoro find-slot --duration 45m --between 09:00-18:00 --days 5 --tz Europe/Berlin
+
+figoro find-slot --duration 1h --buffer 15m --with alice@example.com --via work
+
+Working hours, time zone, buffer and all-day policy can be set in config under
+slots.workingHours, slots.timezone, slots.buffer and slots.allDay.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := findSlots(cmd.Context(), os.Stdout)
+		if (err != nil) {
+			showError("failed to find free slots", err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(findSlotCmd)
+
+	slotDuration = managedflag.NewDuration(findSlotCmd, "duration", 30 * time.Minute, "duration of the meeting")
+	slotDays = managedflag.NewInt64(findSlotCmd, "days", 5, "number of days to search")
+	slotFrom = managedflag.NewStr(findSlotCmd, "from", "", "first day to search, e.g. 2024-05-06, tomorrow or next monday (default today)")
+	slotWeekends = managedflag.NewBool(findSlotCmd, "weekends", false, "search on Saturdays and Sundays too")
+	slotWith = managedflag.NewStrSlice(findSlotCmd, "with", nil, "emails of other people whose calendars have to be free")
+	slotVia = managedflag.NewStr(findSlotCmd, "via", "", "account used to query calendars of other people (default first account)")
+	slotOutput = managedflag.NewStrP(findSlotCmd, "output", "o", "text", "output format [text, json]")
+
+	findSlotCmd.Flags().String("between", "09:00-18:00", "working hours, HH:MM-HH:MM")
+	findSlotCmd.Flags().String("tz", "", "IANA time zone of working hours (default time.zone from config or local)")
+	findSlotCmd.Flags().Duration("buffer", 0, "free time to keep before and after other meetings")
+	findSlotCmd.Flags().String("all-day", "busy", "treat all-day events as [busy, free]")
+
+	viper.SetDefault(workingHoursConfigKey, "09:00-18:00")
+	viper.SetDefault(slotAllDayConfigKey, "busy")
+	viper.BindPFlag(workingHoursConfigKey, findSlotCmd.Flags().Lookup("between"))
+	viper.BindPFlag(slotTimeZoneConfigKey, findSlotCmd.Flags().Lookup("tz"))
+	viper.BindPFlag(slotBufferConfigKey, findSlotCmd.Flags().Lookup("buffer"))
+	viper.BindPFlag(slotAllDayConfigKey, findSlotCmd.Flags().Lookup("all-day"))
+}
+
+// parseWorkingHours parses HH:MM-HH:MM into offsets from midnight
+func parseWorkingHours(value string) (time.Duration, time.Duration, error) {
+	startValue, endValue, ok := strings.Cut(value, "-")
+	if (!ok) {
+		return 0, 0, fmt.Errorf("invalid working hours '%s', expected HH:MM-HH:MM", value)
+	}
+	start, err := time.Parse(workingHoursLayout, strings.TrimSpace(startValue))
+	if (err != nil) {
+		return 0, 0, fmt.Errorf("invalid start of working hours '%s'", startValue)
+	}
+	end, err := time.Parse(workingHoursLayout, strings.TrimSpace(endValue))
+	if (err != nil) {
+		return 0, 0, fmt.Errorf("invalid end of working hours '%s'", endValue)
+	}
+	if (!end.After(start)) {
+		return 0, 0, fmt.Errorf("working hours '%s' end before they start", value)
+	}
+	midnight := time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return start.Sub(midnight), end.Sub(midnight), nil
+}
+
+// workingWindows returns working hours of each searched day, today's window starts no earlier than now.
+// Days whose working hours are already over are skipped without counting them
+func workingWindows(firstDay time.Time, days int, weekends bool, dayStart time.Duration, dayEnd time.Duration) []interval.Interval {
+	windows := make([]interval.Interval, 0, days)
+	now := time.Now()
+	for day := firstDay; len(windows) < days; day = day.AddDate(0, 0, 1) {
+		if (!weekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday)) {
+			continue
+		}
+		window := interval.New(timeOfDay(day, dayStart), timeOfDay(day, dayEnd))
+		if (!window.End.After(now)) {
+			continue
+		}
+		if (window.Start.Before(now)) {
+			window.Start = now.Truncate(time.Minute)
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
+
+// timeOfDay returns the wall clock time of the day, which is not midnight plus offset on days when clocks change
+func timeOfDay(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(offset / time.Hour), int(offset % time.Hour / time.Minute), 0, 0, day.Location())
+}
+
+func getBusy(ctx context.Context, accounts []gaccount.GAccount, period interval.Interval, location *time.Location) ([]interval.Interval, error) {
+	allDay := viper.GetString(slotAllDayConfigKey)
+	if (allDay != "busy" && allDay != "free") {
+		return nil, fmt.Errorf("invalid config for %s: '%s', expected busy or free", slotAllDayConfigKey, allDay)
+	}
+
+	account, err := combaccount.New(serviceName, accounts)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to initialize accounts: %v: %v", accounts, err)
+	}
+
+	busy, err := account.Busy(ctx, period.Start, period.End, &combaccount.BusyOptions{ AllDayBusy: allDay == "busy", Location: location })
+	if (err != nil) {
+		return nil, err
+	}
+	if (len(*slotWith.Value) == 0) {
+		return busy, nil
+	}
+
+	if (len(accounts) == 0) {
+		return nil, fmt.Errorf("no accounts configured to query calendars of other people")
+	}
+	via := &accounts[0]
+	if (slotVia.IsChanged()) {
+		index := slices.IndexFunc(accounts, func(acc gaccount.GAccount) bool { return acc.Name == *slotVia.Value })
+		if (index < 0) {
+			return nil, fmt.Errorf("account '%s' does not exist in config", *slotVia.Value)
+		}
+		via = &accounts[index]
+	}
+
+	othersBusy, err := via.FreeBusy(ctx, *slotWith.Value, period.Start, period.End)
+	if (err != nil) {
+		return nil, err
+	}
+	return interval.Merge(append(busy, othersBusy...)), nil
+}
+
+func findSlots(ctx context.Context, w io.Writer) error {
+	parser, err := newTimeParser()
+	if (err != nil) {
+		return err
+	}
+	// working hours may be kept in their own zone, times in flags are read in it too
+	if (viper.GetString(slotTimeZoneConfigKey) != "") {
+		location, err := time.LoadLocation(viper.GetString(slotTimeZoneConfigKey))
+		if (err != nil) {
+			return fmt.Errorf("invalid time zone: %w", err)
+		}
+		parser = timeparse.New(location, parser.WeekStart)
+	}
+	location := parser.Location
+
+	dayStart, dayEnd, err := parseWorkingHours(viper.GetString(workingHoursConfigKey))
+	if (err != nil) {
+		return err
+	}
+	if (*slotDays.Value < 1) {
+		return fmt.Errorf("--days must be at least 1")
+	}
+
+	firstDay := parser.Now
+	if (slotFrom.IsChanged()) {
+		firstDay, err = parseTimeFlag(parser, "from", *slotFrom.Value, parser.Now)
+		if (err != nil) {
+			return err
+		}
+	}
+
+	windows := workingWindows(firstDay, int(*slotDays.Value), *slotWeekends.Value, dayStart, dayEnd)
+	period := interval.New(windows[0].Start, windows[len(windows) - 1].End)
+	if (!period.Start.Before(period.End)) {
+		return fmt.Errorf("working hours of the searched days are already over")
+	}
+
+	busy, err := getBusy(ctx, getAccountsFromConfig(), period, location)
+	if (err != nil) {
+		return err
+	}
+
+	buffer := viper.GetDuration(slotBufferConfigKey)
+	buffered := make([]interval.Interval, len(busy))
+	for i, current := range busy {
+		buffered[i] = current.Expand(buffer)
+	}
+
+	slots := make([]interval.Interval, 0)
+	for _, window := range windows {
+		for _, free := range interval.Free(window, buffered) {
+			if (free.Duration() >= *slotDuration.Value) {
+				slots = append(slots, free)
+			}
+		}
+	}
+
+	if (len(slots) == 0 && *slotOutput.Value == "text") {
+		fmt.Fprintln(w, "no free slots found")
+		return nil
+	}
+	return renderIntervals(w, *slotOutput.Value, slots, location)
+}
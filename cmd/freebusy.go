@@ -0,0 +1,130 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/combaccount"
+	"github.com/EugeneShtoka/figoro/lib/eventsrender"
+	"github.com/EugeneShtoka/figoro/lib/interval"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/EugeneShtoka/figoro/lib/timeparse"
+	"github.com/spf13/cobra"
+)
+
+var (
+	freeBusyPeriod	*timeRangeFlags
+	freeBusyOutput	*managedflag.StrFlag
+
+	defaultFreeBusySpan = 7 * 24 * time.Hour
+	busyTimeLayout = "Mon 2006-01-02 15:04"
+)
+
+var freeBusyCmd = &cobra.Command{
+	Use:   "freebusy",
+	Args:  cobra.NoArgs,
+	Short: "Show when you are busy",
+	Long: `Show busy intervals of all accounts merged into one timeline, based on the free/busy
+information of the calendars shown for each account. For example:
+
+figoro freebusy --from "2024-05-06 09:00" --to "2024-05-10 18:00"
+
+figoro freebusy --range next-week --tz America/New_York
+
+` + timeFlagsHelp,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := showFreeBusy(cmd.Context(), os.Stdout)
+		if (err != nil) {
+			showError("failed to query free/busy", err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(freeBusyCmd)
+
+	freeBusyPeriod = newTimeRangeFlags(freeBusyCmd, "start of the period (default now)", "end of the period (default a week after start)")
+	freeBusyOutput = managedflag.NewStrP(freeBusyCmd, "output", "o", "text", "output format [text, json]")
+}
+
+// getPeriod reads the period of the flags, defaulting to span starting now
+func getPeriod(parser *timeparse.Parser, flags *timeRangeFlags, span time.Duration) (time.Time, time.Time, error) {
+	from, to, err := flags.period(parser)
+	if (err != nil) {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if (from == nil) {
+		from = &parser.Now
+	}
+	if (to == nil) {
+		end := from.Add(span)
+		to = &end
+	}
+	if (!to.After(*from)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end of the period must be after its start")
+	}
+	return *from, *to, nil
+}
+
+func renderIntervals(w io.Writer, format string, intervals []interval.Interval, location *time.Location) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(intervals)
+	case "text":
+		for _, busy := range intervals {
+			_, err := fmt.Fprintf(w, "%s - %s  (%s)\n", busy.Start.In(location).Format(busyTimeLayout), busy.End.In(location).Format(busyTimeLayout), eventsrender.ShortDuration(busy.Duration()))
+			if (err != nil) {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format '%s', expected one of [text, json]", format)
+}
+
+func showFreeBusy(ctx context.Context, w io.Writer) error {
+	parser, err := newTimeParser()
+	if (err != nil) {
+		return err
+	}
+	from, to, err := getPeriod(parser, freeBusyPeriod, defaultFreeBusySpan)
+	if (err != nil) {
+		return err
+	}
+
+	accounts := getAccountsFromConfig()
+	account, err := combaccount.New(serviceName, accounts)
+	if (err != nil) {
+		return fmt.Errorf("failed to initialize accounts: %v: %v", accounts, err)
+	}
+
+	busy, err := account.FreeBusy(ctx, from, to)
+	if (err != nil) {
+		return err
+	}
+
+	return renderIntervals(w, *freeBusyOutput.Value, busy, parser.Location)
+}
@@ -0,0 +1,182 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/EugeneShtoka/figoro/lib/ics"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/calendar/v3"
+)
+
+var (
+	importIcsTarget	*eventTarget
+	importIcsDryRun	*managedflag.BoolFlag
+)
+
+var importIcsCmd = &cobra.Command{
+	Use:   "ics [file]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Import events from iCalendar file",
+	Long: `Import events from an iCalendar (.ics) file into a calendar of an account, "-" reads stdin.
+Events whose iCalUID already exists in the calendar are skipped, so importing the same file twice is safe. For example:
+
+figoro import ics invitation.ics --account work --calendar primary --dry-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := importIcs(args[0])
+		if (err != nil) {
+			showError(fmt.Sprintf("failed to import events from '%s'", args[0]), err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importIcsCmd)
+
+	importIcsTarget = newEventTarget(importIcsCmd, false)
+	importIcsDryRun = managedflag.NewBool(importIcsCmd, "dry-run", false, "only report which events would be imported")
+}
+
+func readIcs(path string) ([]*calendar.Event, error) {
+	var r io.Reader = os.Stdin
+	if (path != "-") {
+		file, err := os.Open(path)
+		if (err != nil) {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+	return ics.Decode(r)
+}
+
+// isImported reports whether the calendar already has the event, modified instances of recurring
+// events share iCalUID with their master and are told apart by their original start time
+func isImported(account *gaccount.GAccount, calendarId string, event *calendar.Event) (bool, error) {
+	existing, err := account.FindByICalUID(calendarId, event.ICalUID)
+	if (err != nil) {
+		return false, err
+	}
+	for _, candidate := range existing {
+		if (event.OriginalStartTime == nil && candidate.RecurringEventId == "") {
+			return true, nil
+		}
+		if (event.OriginalStartTime != nil && candidate.OriginalStartTime != nil &&
+			sameEventTime(event.OriginalStartTime, candidate.OriginalStartTime)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func sameEventTime(a *calendar.EventDateTime, b *calendar.EventDateTime) bool {
+	if (a.Date != "" || b.Date != "") {
+		return a.Date == b.Date
+	}
+	aTime, aErr := time.Parse(time.RFC3339, a.DateTime)
+	bTime, bErr := time.Parse(time.RFC3339, b.DateTime)
+	return aErr == nil && bErr == nil && aTime.Equal(bTime)
+}
+
+func describeEvent(event *calendar.Event) string {
+	start := event.Start.Date
+	if (start == "") {
+		start = event.Start.DateTime
+	}
+	return fmt.Sprintf("'%s' at %s (%s)", event.Summary, start, event.ICalUID)
+}
+
+// validateImport checks all events before any of them is imported, so that a bad file changes nothing
+func validateImport(events []*calendar.Event) error {
+	invalid := make([]string, 0)
+	for _, event := range events {
+		if (event.ICalUID == "") {
+			invalid = append(invalid, describeEvent(event))
+		}
+	}
+	if (len(invalid) > 0) {
+		return fmt.Errorf("nothing was imported, %d event(s) have no UID: %s", len(invalid), strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+func importIcs(path string) error {
+	events, err := readIcs(path)
+	if (err != nil) {
+		return err
+	}
+
+	var account *gaccount.GAccount
+	if (*importIcsDryRun.Value) {
+		account, err = getAccountFromConfig(*importIcsTarget.account.Value)
+	} else {
+		account, err = importIcsTarget.getWritableAccount()
+	}
+	if (err != nil) {
+		return err
+	}
+
+	calendarId, err := importIcsTarget.calendarId(account)
+	if (err != nil) {
+		return err
+	}
+	err = validateImport(events)
+	if (err != nil) {
+		return err
+	}
+
+	imported, skipped := 0, 0
+	for _, event := range events {
+		exists, err := isImported(account, calendarId, event)
+		if (err != nil) {
+			return err
+		}
+		if (exists) {
+			skipped++
+			fmt.Printf("skipped %s: already exists\n", describeEvent(event))
+			continue
+		}
+
+		if (*importIcsDryRun.Value) {
+			imported++
+			fmt.Printf("would import %s\n", describeEvent(event))
+			continue
+		}
+
+		_, err = account.ImportEvent(calendarId, event)
+		if (err != nil) {
+			return err
+		}
+		imported++
+		fmt.Printf("imported %s\n", describeEvent(event))
+	}
+
+	verb := "imported"
+	if (*importIcsDryRun.Value) {
+		verb = "would be imported"
+	}
+	fmt.Printf("%d events %s, %d skipped as duplicates\n", imported, verb, skipped)
+	return nil
+}
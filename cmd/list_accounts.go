@@ -18,6 +18,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
 
 	"github.com/spf13/cobra"
 )
@@ -41,26 +47,54 @@ func listAccountsFromConfig() {
 	//fmt.Printf("Authorized accounts: %s\n", strings.Join(xiter.ToSlice(accountsNames), ", "))
 
 	for _, account := range accounts {
-		fmt.Printf("Account %s\n", account.Name)
-		fmt.Printf("Showing info for calendars:\n")
-		for	_, name := range account.ResolveCalendars() {
-			fmt.Printf("\t%s\n", name)
-		}
-		fmt.Printf("Available calendars:\n")
-		for	_, name := range account.Calendars.All {
-			fmt.Printf("\t%s\n", name)
+		synced := "never"
+		if (!account.Calendars.Synced.IsZero()) {
+			synced = account.Calendars.Synced.Local().Format("2006-01-02 15:04")
 		}
-		if (len(account.Calendars.WhiteList) > 0) {
-			fmt.Printf("Whitelisted calendars:\n")
-			for	_, calendar := range account.Calendars.WhiteList {
-				fmt.Printf("\t%s\n", calendar)
-			}
+		fmt.Printf("Account %s (calendars synced %s)\n", account.Name, synced)
+
+		shown := account.ResolveCalendars()
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "\tSHOWN\tNAME\tID\tROLE\tTIME ZONE\tCOLOR\tFLAGS")
+		for	_, cal := range account.Calendars.All {
+			fmt.Fprintf(writer, "\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", yesNo(slices.Contains(shown, cal.ID)), cal.Name(), cal.ID,
+				cal.AccessRole, cal.TimeZone, cal.Color, strings.Join(calendarFlags(&account, &cal), ","))
 		}
-		if (len(account.Calendars.BlackList) > 0) {
-			fmt.Printf("Blacklisted calendars:\n")
-			for	_, calendar := range account.Calendars.BlackList {
-				fmt.Printf("\t%s\n", calendar)
+		writer.Flush()
+
+		// entries of calendars which are gone until the next sync calendars
+		for	_, id := range append(slices.Clone(account.Calendars.WhiteList), account.Calendars.BlackList...) {
+			if (account.Calendars.Find(id) == nil) {
+				fmt.Printf("\tunknown calendar %s is included or excluded, run 'figoro sync calendars %s'\n", id, account.Name)
 			}
 		}
 	}
 }
+
+func calendarFlags(account *gaccount.GAccount, cal *gaccount.Calendar) []string {
+	flags := make([]string, 0)
+	if (cal.Primary) {
+		flags = append(flags, "primary")
+	}
+	if (cal.Hidden) {
+		flags = append(flags, "hidden")
+	}
+	// calendars of configs written by older versions have no metadata until synced
+	if (cal.AccessRole != "" && !cal.Selected) {
+		flags = append(flags, "unselected")
+	}
+	if (slices.Contains(account.Calendars.WhiteList, cal.ID)) {
+		flags = append(flags, "included")
+	}
+	if (slices.Contains(account.Calendars.BlackList, cal.ID)) {
+		flags = append(flags, "excluded")
+	}
+	return flags
+}
+
+func yesNo(value bool) string {
+	if (value) {
+		return "yes"
+	}
+	return "no"
+}
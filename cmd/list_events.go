@@ -17,123 +17,114 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"time"
+	"io"
+	"os"
+	"strings"
 
-	"github.com/EugeneShtoka/figoro/lib/combaccount"
-	"github.com/EugeneShtoka/figoro/lib/eventsfilter"
+	"github.com/EugeneShtoka/figoro/lib/eventsrender"
 	"github.com/EugeneShtoka/figoro/lib/managedflag"
 	"github.com/spf13/cobra"
-	"google.golang.org/api/calendar/v3"
 )
 
 var (
-	minEndTime		*managedflag.StrFlag
-	maxStartTime	*managedflag.StrFlag
-	eventTypes		*managedflag.StrFlag
-	orderBy			*managedflag.StrFlag
+	listEventsQuery	*eventsQuery
 
-	maxResults		*managedflag.Int64Flag
-
-	single			*managedflag.BoolFlag
-	deleted			*managedflag.BoolFlag
+	output			*managedflag.StrFlag
+	columns			*managedflag.StrSliceFlag
+	templateText	*managedflag.StrFlag
+	templateFile	*managedflag.StrFlag
 )
 
 var listEventsCmd = &cobra.Command{
 	Use:   "events",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
+	Short: "List events",
+	Long: `List events of all configured accounts merged into one list. For example:
+
+figoro list events --to 2024-01-01 -o agenda
+
+figoro list events --range this-week --calendar work:primary
+
+figoro list events -o csv --columns start,end,summary,location
+
+figoro list events --maxResults 1 --template '{{.Start | time "15:04"}} {{.Summary}} ({{.Account}})'
+
+Template helpers: time LAYOUT, timeIn ZONE LAYOUT, duration, until, truncate N,
+color NAME|#RRGGBB, attendees SEPARATOR, join, upper, lower, default.
+
+` + timeFlagsHelp + `
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+Output is a table when printed to a terminal and json otherwise.
+
+With --partial, which is the default in a terminal, events of healthy accounts are shown when others fail.
+Failures are summarized on stderr, json output becomes {"events": [...], "warnings": [...]}
+and figoro exits with code 3.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		events, err := listEvents()
+		err := listEvents(cmd.Context(), os.Stdout)
 		if (err != nil) {
 			showError("failed to list events", err)
 			cmd.Usage() 
 		}
-		fmt.Println(events)
 	},
 }
 
 func init() {
 	listCmd.AddCommand(listEventsCmd)
 
-	minEndTime = managedflag.NewStr(listEventsCmd, "minEndTime", "", "list events with end times later than (default now)")
-	maxStartTime = managedflag.NewStr(listEventsCmd, "maxStartTime", "", "list events with start times earlier than")
-	eventTypes = managedflag.NewStr(listEventsCmd, "eventTypes", "", "list events with specified event types")
-	orderBy = managedflag.NewStr(listEventsCmd, "orderBy", "", "list events with specified order")
-
-	maxResults = managedflag.NewInt64(listEventsCmd, "maxResults", 0, "max results per account")
+	listEventsQuery = newEventsQuery(listEventsCmd)
 
-	single = managedflag.NewBool(listEventsCmd, "single", false, "list events for all accounts")
-	deleted = managedflag.NewBool(listEventsCmd, "deleted", false, "list events for all accounts")
+	output = managedflag.NewStrP(listEventsCmd, "output", "o", "", fmt.Sprintf("output format %v (default table for terminal, json otherwise)", eventsrender.Formats))
+	columns = managedflag.NewStrSlice(listEventsCmd, "columns", nil, fmt.Sprintf("columns to output [%s] (default %s)", strings.Join(eventsrender.ColumnNames(), ", "), strings.Join(eventsrender.DefaultColumns, ",")))
+	templateText = managedflag.NewStr(listEventsCmd, "template", "", "Go template executed for each event, e.g. '{{.Start | time \"15:04\"}} {{.Summary}}'")
+	templateFile = managedflag.NewStr(listEventsCmd, "template-file", "", "path to a file with Go template executed for each event")
+	listEventsCmd.MarkFlagsMutuallyExclusive("template", "template-file", "output")
 }
 
-func eventsToString(events []*calendar.Event) (string, error) {
-	if len(events) == 0 {
-		return "[]", nil
-	}
-	
-	jsonData, err := json.Marshal(events)
-	if err != nil {
-		return "", err
+func getRenderer() (*eventsrender.Renderer, error) {
+	if (templateText.IsChanged()) {
+		return eventsrender.NewTemplate(*templateText.Value)
 	}
-
-	return string(jsonData), nil
-}
-
-func listEvents() (string, error) {
-		accounts := getAccountsFromConfig()
-		account, err := combaccount.New(serviceName, accounts)
+	if (templateFile.IsChanged()) {
+		data, err := os.ReadFile(*templateFile.Value)
 		if (err != nil) {
-			return "", fmt.Errorf("failed to initialize accounts: %v: %v", accounts, err)
-		} 
-
-		minEndTimeValue := time.Now().Format(time.RFC3339)
-		if (minEndTime.IsChanged()) {
-			minEndTimeValue = *minEndTime.Value
-		}
-		filter := eventsfilter.New().MinEndTime(minEndTimeValue)
-
-		if (maxStartTime.IsChanged()) {
-			filter = filter.MaxStartTime(*maxStartTime.Value)
-		}
-
-		if (eventTypes.IsChanged()) {
-			filter = filter.EventTypes(*eventTypes.Value)
-		}
-
-		if (orderBy.IsChanged()) {
-			filter = filter.OrderBy(*orderBy.Value)
+			return nil, fmt.Errorf("failed to read template file: %w", err)
 		}
+		return eventsrender.NewTemplate(string(data))
+	}
 
-		if (maxResults.IsChanged()) {
-			filter = filter.MaxResults(*maxResults.Value)
+	format := *output.Value
+	if (format == "") {
+		format = "json"
+		if (isTerminal()) {
+			format = "table"
 		}
+	}
+	return eventsrender.New(format, *columns.Value, terminalWidth())
+}
 
-		if (single.IsChanged() && *single.Value) {
-			filter = filter.ShowSingle()
+func listEvents(ctx context.Context, w io.Writer) error {
+		renderer, err := getRenderer()
+		if (err != nil) {
+			return err
 		}
 
-		if (deleted.IsChanged() && *deleted.Value) {
-			filter = filter.ShowDeleted()
+		filter, err := listEventsQuery.filter()
+		if (err != nil) {
+			return err
 		}
-
-		events, err := account.Events(filter)
+		events, warnings, err := listEventsQuery.events(ctx, filter)
 		if (err != nil) {
-			return "", fmt.Errorf("failed to retrieve events for accounts: %v: %v", accounts, err)
+			return err
 		}
+		renderer.Partial = listEventsQuery.isPartial()
+		renderer.Warnings = toRenderWarnings(warnings)
+		reportWarnings(warnings)
 
-		//ToDo: reorder combined list of events + limit to maxResults
-		
-		eventsString, err := eventsToString(events)
+		err = renderer.Render(w, events)
 		if (err != nil) {
-			return "", fmt.Errorf("failed to convert events to string for accounts: %v: %v", accounts, err)
+			return fmt.Errorf("failed to render events: %w", err)
 		}
 
-		return eventsString, nil
+		return nil
 }
@@ -17,9 +17,12 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 
+	"github.com/EugeneShtoka/figoro/lib/gaseed"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -30,8 +33,13 @@ var (
 	logger zerolog.Logger
 	logLevel string
 	serviceName = "figoro"
+	// exitCode is set by commands which succeed only partially
+	exitCode = 0
 )
 
+// exitPartial is the exit code of commands which output results while some accounts or calendars failed
+const exitPartial = 3
+
 var rootCmd = &cobra.Command{
 	Use:   "figoro",
 	Version: "0.0.1",
@@ -39,18 +47,26 @@ var rootCmd = &cobra.Command{
 	Long: `List events from multiple Google Calendars, offering customizable filtering. 
 For example:
 
-figoro --calendar "Work" --start "2023-12-25" --end "2024-01-01"
+figoro list events --to "next friday 18:00"
 
-figoro --mode "json" --start "2023-12-25" --end "2024-01-01".`,
+figoro list events --output agenda --columns start,end,summary,location`,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Ctrl+C cancels requests in flight instead of leaving them to run to completion
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
+	if exitCode != 0 {
+		stop()
+		os.Exit(exitCode)
+	}
 }
 
 func init() {
@@ -92,13 +108,16 @@ func initRootCmdConfig() {
 	}
 
 	logger = zerolog.New(os.Stderr).With().Timestamp().Logger().Level(level)
+	gaseed.SetLogger(logger)
 	logger.Debug().Msgf("reading configuration from: %s\n", viper.ConfigFileUsed())
+
+	err = initSecrets()
+	if err != nil {
+		showError(fmt.Sprintf("invalid config for %s", secretsBackendConfigKey), err)
+	}
 }
 
 // TODO: fix list events documentation
 // TODO: add test cases
-// TODO: add event commands: add, delete, update
 // TODO: build CI/CD for the project
-// TODO: add commands to manage whitelist & blacklist of calendars
-// TODO: add optional flags to limit events to specific list of calendars
 // TODO: extract part of the code to external packages
\ No newline at end of file
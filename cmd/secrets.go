@@ -0,0 +1,139 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/EugeneShtoka/figoro/lib/typedkeyring"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	secretsBackendConfigKey = "secrets.backend"
+	secretsFileConfigKey = "secrets.file"
+	passphraseEnv = "FIGORO_PASSPHRASE"
+	defaultSecretsBackend = "keyring"
+	secretsBackends = []string{"keyring", "file"}
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage storage of tokens",
+	Long: `Manage storage of tokens. Tokens are kept in the keyring of the OS by default, machines without one,
+such as headless servers and containers, can keep them in a file encrypted with a passphrase:
+
+secrets:
+  backend: file
+  file: ~/.config/figoro/secrets.enc
+
+The passphrase is read from the FIGORO_PASSPHRASE environment variable or prompted for.`,
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+}
+
+// initSecrets makes keyrings use the backend selected in config
+func initSecrets() error {
+	name := viper.GetString(secretsBackendConfigKey)
+	if (name == "") {
+		name = defaultSecretsBackend
+	}
+	backend, err := newSecretsBackend(name)
+	if (err != nil) {
+		return err
+	}
+	typedkeyring.SetDefaultBackend(backend)
+	return nil
+}
+
+func newSecretsBackend(name string) (typedkeyring.Backend, error) {
+	switch name {
+	case "keyring":
+		return typedkeyring.NewOSBackend(), nil
+	case "file":
+		path, err := getSecretsFile()
+		if (err != nil) {
+			return nil, err
+		}
+		return typedkeyring.NewFileBackend(path, getPassphrase), nil
+	}
+	return nil, fmt.Errorf("unknown secrets backend '%s', expected one of %v", name, secretsBackends)
+}
+
+func getSecretsFile() (string, error) {
+	path := viper.GetString(secretsFileConfigKey)
+	if (path != "") {
+		return expandHome(path)
+	}
+
+	dir, err := os.UserConfigDir()
+	if (err != nil) {
+		return "", fmt.Errorf("failed to find config dir for secrets file, set %s: %w", secretsFileConfigKey, err)
+	}
+	return filepath.Join(dir, serviceName, "secrets.enc"), nil
+}
+
+func expandHome(path string) (string, error) {
+	if (len(path) < 2 || path[:2] != "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if (err != nil) {
+		return "", err
+	}
+	return filepath.Join(home, path[2:]), nil
+}
+
+// getPassphrase asks for the passphrase twice when the secrets file is created
+func getPassphrase(confirm bool) (string, error) {
+	passphrase, ok := os.LookupEnv(passphraseEnv)
+	if (ok) {
+		return passphrase, nil
+	}
+
+	label := "Enter passphrase of secrets file"
+	if (confirm) {
+		label = "Enter passphrase for new secrets file"
+	}
+	prompt := promptui.Prompt{
+		Label:	label,
+		Mask:	'*',
+	}
+	passphrase, err := prompt.Run()
+	if (err != nil || !confirm) {
+		return passphrase, err
+	}
+
+	confirmation := promptui.Prompt{
+		Label:	"Repeat passphrase",
+		Mask:	'*',
+	}
+	repeated, err := confirmation.Run()
+	if (err != nil) {
+		return "", err
+	}
+	if (repeated != passphrase) {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
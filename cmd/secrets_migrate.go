@@ -0,0 +1,127 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/EugeneShtoka/figoro/lib/typedkeyring"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	migrateFrom			*managedflag.StrFlag
+	migrateTo			*managedflag.StrFlag
+	migrateKeepSource	*managedflag.BoolFlag
+)
+
+var secretsMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move tokens of all accounts to another backend",
+	Long: fmt.Sprintf(`Move tokens of all accounts to another backend %v and select it in config. For example:
+
+figoro secrets migrate --to file`, secretsBackends),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := migrateSecrets()
+		if (err != nil) {
+			showError("failed to migrate secrets", err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsMigrateCmd)
+
+	migrateFrom = managedflag.NewStr(secretsMigrateCmd, "from", "", "backend to move tokens from (default the one in config)")
+	migrateTo = managedflag.NewStr(secretsMigrateCmd, "to", "", fmt.Sprintf("backend to move tokens to %v", secretsBackends))
+	migrateKeepSource = managedflag.NewBool(secretsMigrateCmd, "keep-source", false, "leave copies of tokens in the source backend")
+	secretsMigrateCmd.MarkFlagRequired("to")
+}
+
+func migrateSecrets() error {
+	fromName := *migrateFrom.Value
+	if (fromName == "") {
+		fromName = viper.GetString(secretsBackendConfigKey)
+	}
+	if (fromName == "") {
+		fromName = defaultSecretsBackend
+	}
+	toName := *migrateTo.Value
+	if (fromName == toName) {
+		return fmt.Errorf("tokens are already kept in '%s'", toName)
+	}
+
+	from, err := newSecretsBackend(fromName)
+	if (err != nil) {
+		return err
+	}
+	to, err := newSecretsBackend(toName)
+	if (err != nil) {
+		return err
+	}
+
+	accounts, err := readAccountsFromConfig()
+	if (err != nil) {
+		return fmt.Errorf("failed to read accounts from config: %w", err)
+	}
+
+	// copy everything first, so that a failure leaves the source complete
+	migrated := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		value, err := from.Get(serviceName, account.Name)
+		if (errors.Is(err, typedkeyring.ErrNotFound)) {
+			showError(fmt.Sprintf("skipping account '%s'", account.Name), err)
+			continue
+		}
+		if (err != nil) {
+			return fmt.Errorf("failed to read token of account '%s' from '%s', config was not changed: %w", account.Name, fromName, err)
+		}
+		err = to.Set(serviceName, account.Name, value)
+		if (err != nil) {
+			return fmt.Errorf("failed to save token of account '%s' to '%s': %w", account.Name, toName, err)
+		}
+		migrated = append(migrated, account.Name)
+	}
+
+	if (len(migrated) == 0) {
+		return fmt.Errorf("no tokens found in '%s', config was not changed", fromName)
+	}
+
+	viper.Set(secretsBackendConfigKey, toName)
+	err = writeConfig()
+	if (err != nil) {
+		return fmt.Errorf("tokens were copied, but selecting '%s' in config failed: %w", toName, err)
+	}
+
+	if (!*migrateKeepSource.Value) {
+		for _, name := range migrated {
+			err = from.Delete(serviceName, name)
+			if (err != nil) {
+				showError(fmt.Sprintf("failed to delete token of account '%s' from '%s'", name, fromName), err)
+			}
+		}
+	}
+
+	slices.Sort(migrated)
+	fmt.Printf("moved tokens of %d account(s) %v from '%s' to '%s'\n", len(migrated), migrated, fromName, toName)
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/EugeneShtoka/figoro/lib/pattern"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	groupsConfigKey = "groups"
+)
+
+// calendarSelection holds the flags narrowing a single run to some of the configured accounts and calendars
+type calendarSelection struct {
+	accounts			*managedflag.StrSliceFlag
+	calendars			*managedflag.StrSliceFlag
+	excludedCalendars	*managedflag.StrSliceFlag
+	groups				*managedflag.StrSliceFlag
+}
+
+// calendarRef matches calendars of the accounts matching account, an empty account matches every account
+type calendarRef struct {
+	account		*pattern.Pattern
+	calendar	*pattern.Pattern
+}
+
+func newCalendarSelection(cmd *cobra.Command) *calendarSelection {
+	return &calendarSelection{
+		accounts: managedflag.NewStrSlice(cmd, "account", nil, "use only accounts matching the name, glob or /regex/ (repeatable)"),
+		calendars: managedflag.NewStrSlice(cmd, "calendar", nil, "use only calendars whose ID or name matches, optionally prefixed with 'account:' (repeatable)"),
+		excludedCalendars: managedflag.NewStrSlice(cmd, "exclude-calendar", nil, "skip calendars whose ID or name matches, optionally prefixed with 'account:' (repeatable)"),
+		groups: managedflag.NewStrSlice(cmd, "group", nil, "use calendars of the group from the groups config key, e.g. groups.work: [work:primary, client:team] (repeatable)"),
+	}
+}
+
+// parseCalendarRef parses 'calendar' or 'account:calendar', both parts can be names, globs or /regex/.
+// 'account' alone is written as 'account:*'
+func parseCalendarRef(text string) (*calendarRef, error) {
+	ref := &calendarRef{}
+	calendarText := text
+	if accountText, rest, found := strings.Cut(text, ":"); (found && !strings.HasPrefix(text, "/")) {
+		account, err := pattern.Compile(accountText)
+		if (err != nil) {
+			return nil, err
+		}
+		ref.account = account
+		calendarText = rest
+	}
+	if (calendarText == "") {
+		return nil, fmt.Errorf("calendar is missing in '%s'", text)
+	}
+
+	calendar, err := pattern.Compile(calendarText)
+	if (err != nil) {
+		return nil, err
+	}
+	ref.calendar = calendar
+	return ref, nil
+}
+
+func parseCalendarRefs(texts []string) ([]*calendarRef, error) {
+	refs := make([]*calendarRef, 0, len(texts))
+	for _, text := range texts {
+		ref, err := parseCalendarRef(text)
+		if (err != nil) {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+func (r *calendarRef) matches(accName string, cal gaccount.Calendar) bool {
+	return (r.account == nil || r.account.Match(accName)) && cal.Matches(r.calendar)
+}
+
+func matchesAny(refs []*calendarRef, accName string, cal gaccount.Calendar) bool {
+	return slices.ContainsFunc(refs, func(ref *calendarRef) bool { return ref.matches(accName, cal) })
+}
+
+// groupRefs reads calendars of the named groups from config
+func groupRefs(names []string) ([]*calendarRef, error) {
+	refs := make([]*calendarRef, 0)
+	for _, name := range names {
+		key := fmt.Sprintf("%s.%s", groupsConfigKey, name)
+		if (!viper.IsSet(key)) {
+			return nil, fmt.Errorf("group '%s' is not defined in config key '%s'", name, groupsConfigKey)
+		}
+		groupRefs, err := parseCalendarRefs(viper.GetStringSlice(key))
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid config for group '%s': %w", name, err)
+		}
+		refs = append(refs, groupRefs...)
+	}
+	return refs, nil
+}
+
+func (s *calendarSelection) isSet() bool {
+	return s.accounts.IsChanged() || s.calendars.IsChanged() || s.excludedCalendars.IsChanged() || s.groups.IsChanged()
+}
+
+// apply narrows the calendars of accounts to the selected ones, dropping accounts left without calendars.
+// Calendars of --calendar and --group are combined, the result is intersected with the whitelist or blacklist of each account
+func (s *calendarSelection) apply(accounts []gaccount.GAccount) ([]gaccount.GAccount, error) {
+	if (!s.isSet()) {
+		return accounts, nil
+	}
+
+	accountPatterns := make([]*pattern.Pattern, 0, len(*s.accounts.Value))
+	for _, text := range *s.accounts.Value {
+		p, err := pattern.Compile(text)
+		if (err != nil) {
+			return nil, err
+		}
+		accountPatterns = append(accountPatterns, p)
+	}
+	included, err := parseCalendarRefs(*s.calendars.Value)
+	if (err != nil) {
+		return nil, err
+	}
+	grouped, err := groupRefs(*s.groups.Value)
+	if (err != nil) {
+		return nil, err
+	}
+	included = append(included, grouped...)
+	excluded, err := parseCalendarRefs(*s.excludedCalendars.Value)
+	if (err != nil) {
+		return nil, err
+	}
+
+	selected := make([]gaccount.GAccount, 0, len(accounts))
+	for _, account := range accounts {
+		if (len(accountPatterns) > 0 && !slices.ContainsFunc(accountPatterns, func(p *pattern.Pattern) bool { return p.Match(account.Name) })) {
+			continue
+		}
+		account.Select(func(cal gaccount.Calendar) bool {
+			return (len(included) == 0 || matchesAny(included, account.Name, cal)) && !matchesAny(excluded, account.Name, cal)
+		})
+		if (len(account.ResolveCalendars()) > 0) {
+			selected = append(selected, account)
+		}
+	}
+
+	if (len(selected) == 0) {
+		return nil, fmt.Errorf("no calendar of the configured accounts matches the selection")
+	}
+	return selected, nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	calendarsMaxAgeConfigKey = "calendars.maxAge"
+)
+
+var syncCalendarsCmd = &cobra.Command{
+	Use:   "calendars [account name]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Sync lists of calendars",
+	Long: `Read lists of calendars of all accounts, or of the given one, from Google again, reporting calendars
+which were added or removed. Included and excluded calendars which no longer exist are forgotten,
+unless none of the included calendars exists, which would make all calendars included.
+
+Lists older than calendars.maxAge in config (e.g. 24h) are synced by list events automatically.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := syncCalendars(cmd.Context(), args)
+		if (err != nil) {
+			showError("failed to sync calendars", err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	syncCmd.AddCommand(syncCalendarsCmd)
+
+	viper.SetDefault(calendarsMaxAgeConfigKey, time.Duration(0))
+}
+
+func syncCalendars(ctx context.Context, args []string) error {
+	var accounts []gaccount.GAccount
+	if (len(args) == 1) {
+		account, err := getAccountFromConfig(args[0])
+		if (err != nil) {
+			return err
+		}
+		accounts = []gaccount.GAccount{ *account }
+	} else {
+		accounts = getAccountsFromConfig()
+	}
+
+	var failed int
+	for i := range accounts {
+		err := syncAccountCalendars(ctx, &accounts[i], os.Stdout, true)
+		if (err != nil) {
+			showError(fmt.Sprintf("failed to sync calendars of account '%s'", accounts[i].Name), err)
+			failed++
+		}
+	}
+	if (failed > 0) {
+		return fmt.Errorf("%d of %d account(s) failed", failed, len(accounts))
+	}
+	return nil
+}
+
+// syncAccountCalendars syncs the list of calendars of the account, saves it to config and reports changes to w,
+// an unchanged list is reported only when reportUnchanged is set
+func syncAccountCalendars(ctx context.Context, account *gaccount.GAccount, w io.Writer, reportUnchanged bool) error {
+	changes, err := account.SyncCalendars(ctx)
+	if (err != nil) {
+		return err
+	}
+	err = updateAccountInConfig(account)
+	if (err != nil) {
+		return err
+	}
+
+	if (changes.IsEmpty()) {
+		if (reportUnchanged) {
+			fmt.Fprintf(w, "account '%s': calendars are up to date\n", account.Name)
+		}
+		return nil
+	}
+	fmt.Fprintf(w, "account '%s':\n", account.Name)
+	for _, cal := range changes.Added {
+		fmt.Fprintf(w, "\tadded %s\n", describeCalendar(&cal))
+	}
+	for _, cal := range changes.Removed {
+		fmt.Fprintf(w, "\tremoved %s\n", describeCalendar(&cal))
+	}
+	for _, id := range changes.Pruned {
+		fmt.Fprintf(w, "\tforgot included or excluded %s\n", id)
+	}
+	for _, id := range changes.Kept {
+		fmt.Fprintf(w, "\tkept included %s which no longer exists, include other calendars or reset the account\n", id)
+	}
+	return nil
+}
+
+// refreshStaleCalendars syncs lists of calendars older than calendars.maxAge, and lists never synced,
+// which come from configs of older versions without calendar names. Failures only produce warnings
+func refreshStaleCalendars(ctx context.Context, accounts []gaccount.GAccount) {
+	maxAge := viper.GetDuration(calendarsMaxAgeConfigKey)
+
+	for i := range accounts {
+		stale := accounts[i].Calendars.Synced.IsZero() || (maxAge > 0 && accounts[i].IsStale(maxAge))
+		if (accounts[i].Service == nil || !stale) {
+			continue
+		}
+		err := syncAccountCalendars(ctx, &accounts[i], os.Stderr, false)
+		if (err != nil) {
+			logger.Warn().Err(err).Str("account", accounts[i].Name).Msg("failed to refresh calendars")
+			fmt.Fprintf(os.Stderr, "warning: failed to refresh calendars of account '%s': %v\n", accounts[i].Name, err)
+		}
+	}
+}
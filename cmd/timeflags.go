@@ -0,0 +1,150 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/managedflag"
+	"github.com/EugeneShtoka/figoro/lib/timeparse"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	timeZoneConfigKey = "time.zone"
+	weekStartConfigKey = "time.weekStart"
+	timeFlagsHelp = `Times are RFC3339, '2006-01-02 15:04', 'today', 'tomorrow 9:00', 'next friday 18:00', or offsets
+like '+2h' and '-1d', relative to --from for --to. Days without a time of day are included whole by --to.
+--range takes 'this-week', 'next-month', 'today' and alike, --in takes durations like '3d' or '2w'.
+Times are read in --tz, weeks start by locale unless time.weekStart is set in config.`
+)
+
+// timeRangeFlags holds the flags selecting a period: --from, --to, --range and --in
+type timeRangeFlags struct {
+	from		*managedflag.StrFlag
+	to			*managedflag.StrFlag
+	rangeName	*managedflag.StrFlag
+	in			*managedflag.StrFlag
+}
+
+func init() {
+	rootCmd.PersistentFlags().String("tz", "", "time zone of times in flags, e.g. Europe/Berlin (default local)")
+	viper.BindPFlag(timeZoneConfigKey, rootCmd.PersistentFlags().Lookup("tz"))
+}
+
+func newTimeRangeFlags(cmd *cobra.Command, fromUsage string, toUsage string) *timeRangeFlags {
+	return &timeRangeFlags{
+		from: managedflag.NewStr(cmd, "from", "", fromUsage),
+		to: managedflag.NewStr(cmd, "to", "", toUsage),
+		rangeName: managedflag.NewStr(cmd, "range", "", fmt.Sprintf("period to use, a day like 'today' or one of %v", timeparse.Ranges)),
+		in: managedflag.NewStr(cmd, "in", "", "period from now lasting the duration, e.g. 3d"),
+	}
+}
+
+func (f *timeRangeFlags) isSet() bool {
+	return f.from.IsChanged() || f.to.IsChanged() || f.rangeName.IsChanged() || f.in.IsChanged()
+}
+
+// period returns the times selected by the flags, nil for the ends which are not given.
+// --range and --in set both ends, --from and --to replace them
+func (f *timeRangeFlags) period(parser *timeparse.Parser) (*time.Time, *time.Time, error) {
+	if (f.rangeName.IsChanged() && f.in.IsChanged()) {
+		return nil, nil, fmt.Errorf("--range and --in cannot be used together")
+	}
+
+	var from, to *time.Time
+	var start, end time.Time
+	var err error
+	switch {
+	case f.rangeName.IsChanged():
+		start, end, err = parser.Range(*f.rangeName.Value)
+	case f.in.IsChanged():
+		start, end, err = parser.In(*f.in.Value)
+	}
+	if (err != nil) {
+		return nil, nil, err
+	}
+	if (f.rangeName.IsChanged() || f.in.IsChanged()) {
+		from, to = &start, &end
+	}
+
+	if (f.from.IsChanged()) {
+		parsed, err := parseTimeFlag(parser, "from", *f.from.Value, parser.Now)
+		if (err != nil) {
+			return nil, nil, err
+		}
+		from = &parsed
+	}
+	if (f.to.IsChanged()) {
+		base := parser.Now
+		if (from != nil) {
+			base = *from
+		}
+		parsed, err := parseEndFlag(parser, "to", *f.to.Value, base)
+		if (err != nil) {
+			return nil, nil, err
+		}
+		to = &parsed
+	}
+
+	if (from != nil && to != nil && !to.After(*from)) {
+		return nil, nil, fmt.Errorf("end of the period must be after its start")
+	}
+	return from, to, nil
+}
+
+// newTimeParser reads times in the zone of --tz or time.zone, with weeks starting on time.weekStart or by locale
+func newTimeParser() (*timeparse.Parser, error) {
+	location := time.Local
+	if zone := viper.GetString(timeZoneConfigKey); (zone != "") {
+		var err error
+		location, err = time.LoadLocation(zone)
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid time zone '%s': %w", zone, err)
+		}
+	}
+
+	weekStart := timeparse.LocaleWeekStart()
+	if name := viper.GetString(weekStartConfigKey); (name != "") {
+		var err error
+		weekStart, err = timeparse.ParseWeekday(name)
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid config for %s: %w", weekStartConfigKey, err)
+		}
+	}
+	return timeparse.New(location, weekStart), nil
+}
+
+// parseTimeFlag reads the time of a flag, offsets like '+2h' are relative to base
+func parseTimeFlag(parser *timeparse.Parser, name string, value string, base time.Time) (time.Time, error) {
+	parsed, err := parser.ParseFrom(value, base)
+	if (err != nil) {
+		return time.Time{}, fmt.Errorf("invalid --%s: %w", name, err)
+	}
+	return parsed, nil
+}
+
+// parseEndFlag reads the time of a flag ending a period, days without a time of day are included whole
+func parseEndFlag(parser *timeparse.Parser, name string, value string, base time.Time) (time.Time, error) {
+	parsed, err := parser.ParseEndFrom(value, base)
+	if (err != nil) {
+		return time.Time{}, fmt.Errorf("invalid --%s: %w", name, err)
+	}
+	return parsed, nil
+}
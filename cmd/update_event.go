@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateEventTarget	*eventTarget
+	updateEventFields	*eventFlags
+)
+
+var updateEventCmd = &cobra.Command{
+	Use:   "event [event id]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Update event",
+	Long: `Update event. Requires event id, only the fields passed as flags are changed,
+a new start without --end keeps the duration of the event. For example:
+
+figoro update event -a work 7kq1v5lr0ab7vq4ivv1gnc7tpc --location "Room 4"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := updateEvent(args[0])
+		if (err != nil) {
+			showError(fmt.Sprintf("failed to update event '%s'", args[0]), err)
+			cmd.Usage()
+		}
+	},
+}
+
+func init() {
+	updateCmd.AddCommand(updateEventCmd)
+
+	updateEventTarget = newEventTarget(updateEventCmd, true)
+	updateEventFields = newEventFlags(updateEventCmd)
+}
+
+func updateEvent(eventId string) error {
+	event, err := updateEventFields.toEvent(false)
+	if (err != nil) {
+		return err
+	}
+
+	account, err := updateEventTarget.getWritableAccount()
+	if (err != nil) {
+		return err
+	}
+
+	calendarId, err := updateEventTarget.calendarId(account)
+	if (err != nil) {
+		return err
+	}
+
+	if (event.Start != nil && event.End == nil) {
+		current, err := account.GetEvent(calendarId, eventId)
+		if (err != nil) {
+			return err
+		}
+		event.End, err = shiftedEnd(current, event.Start)
+		if (err != nil) {
+			return err
+		}
+	}
+
+	updated, err := account.PatchEvent(calendarId, eventId, event, *updateEventTarget.sendUpdates.Value)
+	if (err != nil) {
+		return err
+	}
+
+	fmt.Printf("event '%s' was updated: %s\n", updated.Id, updated.HtmlLink)
+	return nil
+}
@@ -18,92 +18,417 @@ package combaccount
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"sort"
+	"time"
 
 	"github.com/EugeneShtoka/figoro/lib/concurrentresult"
 	"github.com/EugeneShtoka/figoro/lib/eventsfilter"
+	"github.com/EugeneShtoka/figoro/lib/eventstore"
+	"github.com/EugeneShtoka/figoro/lib/figevent"
 	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"github.com/EugeneShtoka/figoro/lib/gaseed"
+	"github.com/EugeneShtoka/figoro/lib/interval"
 	"github.com/EugeneShtoka/figoro/lib/sliceutils"
 	"google.golang.org/api/calendar/v3"
 )
 
+// maxConcurrentRequests limits the number of requests sent to Google at once
+const maxConcurrentRequests = 8
+
 type CombinedAccount struct {
 	accounts []gaccount.GAccount
 }
 
-func New(serviceName string, accounts []gaccount.GAccount) (*CombinedAccount, error) {
-	return &CombinedAccount{ accounts }, nil
+// CacheOptions control how CachedEvents uses the local event store
+type CacheOptions struct {
+	Store		*eventstore.Store
+	// MaxAge is the age after which a cached calendar is synced again, zero syncs on every call
+	MaxAge		time.Duration
+	// Offline answers from the store only, without contacting Google
+	Offline		bool
+}
+
+// SourceError is the failure of a single account or calendar, so that the events of others can still be used
+type SourceError struct {
+	Account		string
+	// CalendarID is empty when the whole account failed
+	CalendarID	string
+	Err			error
+}
+
+func (e *SourceError) Error() string {
+	if (e.CalendarID == "") {
+		return fmt.Sprintf("account '%s': %s", e.Account, e.Reason())
+	}
+	return fmt.Sprintf("account '%s', calendar '%s': %s", e.Account, e.CalendarID, e.Reason())
+}
+
+// Reason describes the failure without naming the source
+func (e *SourceError) Reason() string {
+	if (errors.Is(e.Err, gaseed.ErrInvalidGrant)) {
+		// the failed request hides the only thing the user can act on
+		return fmt.Sprintf("%v, re-authorize account '%s'", gaseed.ErrInvalidGrant, e.Account)
+	}
+	return e.Err.Error()
 }
 
-func sortEventsByStartTime(events []*calendar.Event) {
-	sort.Slice(events, func(i, j int) bool {
-		if (events[i].Start.Date == "") {
-			if (events[j].Start.Date == "") {
-				return events[i].Start.DateTime < events[j].Start.DateTime
-			} else {
-				return events[i].Start.DateTime < events[j].Start.Date
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// SourceErrors collects the failed sources from an error returned by Events or CachedEvents,
+// it returns nil when the error has other causes too
+func SourceErrors(err error) []*SourceError {
+	var sourceErrors []*SourceError
+	var collect func(err error) bool
+	collect = func(err error) bool {
+		if sourceError, ok := err.(*SourceError); ok {
+			sourceErrors = append(sourceErrors, sourceError)
+			return true
+		}
+		switch unwrapped := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, inner := range unwrapped.Unwrap() {
+				if (!collect(inner)) {
+					return false
+				}
 			}
-		} else if (events[j].Start.Date == "") {
-			return events[i].Start.Date < events[j].Start.DateTime 
+			return true
+		case interface{ Unwrap() error }:
+			return collect(unwrapped.Unwrap())
 		}
-		return events[i].Start.Date < events[j].Start.Date
-	})
+		return false
+	}
+
+	if (err == nil || !collect(err)) {
+		return nil
+	}
+	return sourceErrors
 }
 
-func sortEventsByUpdated(events []*calendar.Event) {
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].Updated < events[j].Updated
-	})
+func New(serviceName string, accounts []gaccount.GAccount) (*CombinedAccount, error) {
+	return &CombinedAccount{ accounts }, nil
 }
 
-func reapplyFiltersOnCombinedEvents(events []*calendar.Event, filter *eventsfilter.EventsFilter) []*calendar.Event {
-	fmt.Printf("fiter:  %v\n", filter)
-	if filter.IsOrderedByStartTime() {
-		sortEventsByStartTime(events)
+// startTime parses the start of the event, so events in different zones and all-day events,
+// whose dates are taken in local time, are ordered correctly. Cancelled occurrences are ordered by their original start
+func startTime(event *figevent.Event) time.Time {
+	start, err := event.StartTime(time.Local)
+	if (err != nil && event.OriginalStartTime != nil) {
+		start, _ = figevent.ParseTime(event.OriginalStartTime, time.Local)
+	}
+	return start
+}
+
+// startsBefore orders events by start time, ties are broken by ID so that the order is stable between runs
+func startsBefore(a *figevent.Event, aStart time.Time, b *figevent.Event, bStart time.Time) bool {
+	if (!aStart.Equal(bStart)) {
+		return aStart.Before(bStart)
+	}
+	return a.Id < b.Id
+}
+
+func sortEventsByStartTime(events []*figevent.Event) {
+	starts := make(map[*figevent.Event]time.Time, len(events))
+	for _, event := range events {
+		starts[event] = startTime(event)
 	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return startsBefore(events[i], starts[events[i]], events[j], starts[events[j]])
+	})
+}
 
+func sortEventsByUpdated(events []*figevent.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if (events[i].Updated != events[j].Updated) {
+			return events[i].Updated < events[j].Updated
+		}
+		return events[i].Id < events[j].Id
+	})
+}
+
+func reapplyFiltersOnCombinedEvents(events []*figevent.Event, filter *eventsfilter.EventsFilter) []*figevent.Event {
+	// events come from several calendars and from maps of the cache, so they are always sorted,
+	// otherwise maxResults would keep a different subset on every run
 	if filter.IsOrderedByUpdated() {
 		sortEventsByUpdated(events)
+	} else {
+		sortEventsByStartTime(events)
 	}
 
 	maxResults := filter.GetMaxResults()
-	if (maxResults != nil) {
+	if (maxResults != nil && int64(len(events)) > *maxResults) {
 		events = events[:*maxResults]
 	}
 
 	return events
 }
 
-func getEvents(gAcc *gaccount.GAccount, calendar string,  filter *eventsfilter.EventsFilter, concurrentResult *concurrentresult.ConcurrentResult[[]*calendar.Event]) {
-	events, err := gAcc.Events(calendar, filter)
-	if err != nil {
-		concurrentResult.SendError(err)
-		concurrentResult.Cancel()
+// getEvents collects the pages of one calendar, for events which Google does not order by start.
+// They have to be sorted after all of them are read, ordered events are merged by mergeEvents instead
+func getEvents(ctx context.Context, gAcc *gaccount.GAccount, calendarId string, filter *eventsfilter.EventsFilter) ([]*figevent.Event, error) {
+	source := gAcc.Source(calendarId)
+
+	events := make([]*figevent.Event, 0)
+	for event, err := range gAcc.Events(ctx, calendarId, filter) {
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, figevent.New(source, event))
+	}
+	return events, nil
+}
+
+// calendarStream is the pulled iterator of events of one calendar, head is its next event or nil when it is drained
+type calendarStream struct {
+	account		string
+	calendarId	string
+	source		figevent.Source
+	next		func() (*calendar.Event, error, bool)
+	head		*figevent.Event
+	headStart	time.Time
+}
+
+// advance reads the next event, fetching the next page of the calendar when the current one is used up
+func (s *calendarStream) advance() error {
+	event, err, ok := s.next()
+	if (!ok || err != nil) {
+		s.head = nil
+		if (err != nil) {
+			return &SourceError{ Account: s.account, CalendarID: s.calendarId, Err: err }
+		}
+		return nil
 	}
-	concurrentResult.SendResult(events)
+	s.head = figevent.New(s.source, event)
+	s.headStart = startTime(s.head)
+	return nil
 }
 
-func (ca *CombinedAccount) Events(filter *eventsfilter.EventsFilter) ([]*calendar.Event, error) {
-	concurrentResult := concurrentresult.New[[]*calendar.Event](context.Background())
+// mergeEvents merges calendars whose events Google returns ordered by start, reading pages only
+// while they are needed: once maxResults events are merged the remaining pages are never fetched.
+// First pages are read concurrently, failed calendars are reported like in Events
+func (ca *CombinedAccount) mergeEvents(ctx context.Context, filter *eventsfilter.EventsFilter) ([]*figevent.Event, error) {
+	streams := make([]*calendarStream, 0)
+	for i := range ca.accounts {
+		gAcc := &ca.accounts[i]
+		for _, calendarId := range gAcc.ResolveCalendars() {
+			next, stop := iter.Pull2(gAcc.Events(ctx, calendarId, filter))
+			defer stop()
+			streams = append(streams, &calendarStream{
+				account: gAcc.Name,
+				calendarId: calendarId,
+				source: gAcc.Source(calendarId),
+				next: next,
+			})
+		}
+	}
+
+	concurrentResult := concurrentresult.New[*calendarStream](ctx, maxConcurrentRequests)
 	defer concurrentResult.Cancel()
+	for _, stream := range streams {
+		concurrentResult.Go(func(ctx context.Context) (*calendarStream, error) {
+			return stream, stream.advance()
+		})
+	}
+	_, err := concurrentResult.Wait()
+	errs := []error{ err }
+
+	maxResults := filter.GetMaxResults()
+	events := make([]*figevent.Event, 0)
+	var taken *calendarStream
+	for (maxResults == nil || int64(len(events)) < *maxResults) {
+		// the calendar of the last event moves on only when another event is needed, which may fetch a page
+		if (taken != nil) {
+			errs = append(errs, taken.advance())
+		}
+		taken = nil
+		for _, stream := range streams {
+			if (stream.head != nil && (taken == nil || startsBefore(stream.head, stream.headStart, taken.head, taken.headStart))) {
+				taken = stream
+			}
+		}
+		if (taken == nil) {
+			break
+		}
+		events = append(events, taken.head)
+	}
+	return events, errors.Join(errs...)
+}
 
-	calCount := 0
-	for _, gAcc := range ca.accounts {
-		calendars := gAcc.ResolveCalendars()
-		calCount += len(calendars)
-		for _, calendar := range calendars {
-			go getEvents(&gAcc, calendar, filter, concurrentResult)
+// Events fetches events of resolved calendars of all accounts. When some calendars fail, the events
+// of the others are returned together with the joined SourceError of every failed calendar
+func (ca *CombinedAccount) Events(ctx context.Context, filter *eventsfilter.EventsFilter) ([]*figevent.Event, error) {
+	if (filter.IsSingle() && filter.IsOrderedByStartTime()) {
+		events, err := ca.mergeEvents(ctx, filter)
+		if (err != nil) {
+			err = fmt.Errorf("failed to get events: %w", err)
 		}
+		return reapplyFiltersOnCombinedEvents(events, filter), err
 	}
 
-	events2DArr, err := concurrentResult.Results(calCount)
+	concurrentResult := concurrentresult.New[[]*figevent.Event](ctx, maxConcurrentRequests)
+	defer concurrentResult.Cancel()
+
+	for i := range ca.accounts {
+		gAcc := &ca.accounts[i]
+		for _, calendarId := range gAcc.ResolveCalendars() {
+			concurrentResult.Go(func(ctx context.Context) ([]*figevent.Event, error) {
+				events, err := getEvents(ctx, gAcc, calendarId, filter)
+				if (err != nil) {
+					return nil, &SourceError{ Account: gAcc.Name, CalendarID: calendarId, Err: err }
+				}
+				return events, nil
+			})
+		}
+	}
+
+	events2DArr, err := concurrentResult.Wait()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get events: %w", err)
+		err = fmt.Errorf("failed to get events: %w", err)
 	}
 	
 	combinedEvents := sliceutils.FlattenSlice(events2DArr)
 	filteredEvents := reapplyFiltersOnCombinedEvents(combinedEvents, filter)
 
-	return filteredEvents, nil	
-}
\ No newline at end of file
+	return filteredEvents, err
+}
+
+func syncCalendar(ctx context.Context, gAcc *gaccount.GAccount, calendarId string, single bool, cache *eventstore.CalendarCache) error {
+	events, syncToken, err := gAcc.SyncEvents(ctx, calendarId, cache.SyncToken, single)
+	if (errors.Is(err, gaccount.ErrSyncTokenExpired)) {
+		cache.Reset()
+		events, syncToken, err = gAcc.SyncEvents(ctx, calendarId, "", single)
+	}
+	if (err != nil) {
+		return err
+	}
+
+	source := gAcc.Source(calendarId)
+
+	cache.Apply(events, syncToken)
+	cache.Summary = source.CalendarSummary
+	cache.Color = source.CalendarColor
+	return nil
+}
+
+func getCachedEvents(ctx context.Context, gAcc *gaccount.GAccount, calendarId string, filter *eventsfilter.EventsFilter, options *CacheOptions) ([]*figevent.Event, error) {
+	cache, err := options.Store.Load(gAcc.Name, calendarId, filter.IsSingle())
+	if (err != nil) {
+		return nil, err
+	}
+	if (options.Offline && !cache.IsSynced()) {
+		return nil, fmt.Errorf("calendar '%s' of account '%s' was never synced", calendarId, gAcc.Name)
+	}
+	if (!options.Offline && !cache.IsFresh(options.MaxAge)) {
+		err = syncCalendar(ctx, gAcc, calendarId, filter.IsSingle(), cache)
+		if (err == nil) {
+			err = options.Store.Save(gAcc.Name, calendarId, filter.IsSingle(), cache)
+		}
+		if (err != nil) {
+			return nil, err
+		}
+	}
+
+	source := figevent.Source{
+		Account: gAcc.Name,
+		CalendarID: calendarId,
+		CalendarSummary: cache.Summary,
+		CalendarColor: cache.Color,
+	}
+	events := make([]*figevent.Event, 0)
+	for _, event := range cache.List() {
+		if (filter.Match(event)) {
+			events = append(events, figevent.New(source, event))
+		}
+	}
+	return reapplyFiltersOnCombinedEvents(events, filter), nil
+}
+
+// CachedEvents answers from the local event store, fetching only the changes since the last sync
+// for calendars whose cache is older than options.MaxAge. Failures are reported like in Events
+func (ca *CombinedAccount) CachedEvents(ctx context.Context, filter *eventsfilter.EventsFilter, options *CacheOptions) ([]*figevent.Event, error) {
+	concurrentResult := concurrentresult.New[[]*figevent.Event](ctx, maxConcurrentRequests)
+	defer concurrentResult.Cancel()
+
+	for i := range ca.accounts {
+		gAcc := &ca.accounts[i]
+		for _, calendarId := range gAcc.ResolveCalendars() {
+			concurrentResult.Go(func(ctx context.Context) ([]*figevent.Event, error) {
+				events, err := getCachedEvents(ctx, gAcc, calendarId, filter, options)
+				if (err != nil) {
+					return nil, &SourceError{ Account: gAcc.Name, CalendarID: calendarId, Err: err }
+				}
+				return events, nil
+			})
+		}
+	}
+
+	events2DArr, err := concurrentResult.Wait()
+	if err != nil {
+		err = fmt.Errorf("failed to get cached events: %w", err)
+	}
+
+	combinedEvents := sliceutils.FlattenSlice(events2DArr)
+	filteredEvents := reapplyFiltersOnCombinedEvents(combinedEvents, filter)
+
+	return filteredEvents, err
+}
+
+// FreeBusy merges busy intervals of resolved calendars of all accounts into one timeline
+func (ca *CombinedAccount) FreeBusy(ctx context.Context, from time.Time, to time.Time) ([]interval.Interval, error) {
+	concurrentResult := concurrentresult.New[[]interval.Interval](ctx, maxConcurrentRequests).FailFast()
+	defer concurrentResult.Cancel()
+
+	for i := range ca.accounts {
+		gAcc := &ca.accounts[i]
+		concurrentResult.Go(func(ctx context.Context) ([]interval.Interval, error) {
+			return gAcc.FreeBusy(ctx, gAcc.ResolveCalendars(), from, to)
+		})
+	}
+
+	busy2DArr, err := concurrentResult.Wait()
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to get free/busy: %w", err)
+	}
+
+	return interval.Merge(sliceutils.FlattenSlice(busy2DArr)), nil
+}
+
+// BusyOptions control which events take time in Busy
+type BusyOptions struct {
+	// AllDayBusy makes all-day events block the whole day
+	AllDayBusy	bool
+	// Location is used for the dates of all-day events
+	Location	*time.Location
+}
+
+// Busy derives busy intervals from the events of all accounts, skipping declined and transparent events,
+// unlike FreeBusy it can tell all-day events apart
+func (ca *CombinedAccount) Busy(ctx context.Context, from time.Time, to time.Time, options *BusyOptions) ([]interval.Interval, error) {
+	filter := eventsfilter.New().MinEndTime(from.Format(time.RFC3339)).MaxStartTime(to.Format(time.RFC3339)).ShowSingle()
+	events, err := ca.Events(ctx, filter)
+	if (err != nil) {
+		return nil, err
+	}
+
+	busy := make([]interval.Interval, 0, len(events))
+	for _, event := range events {
+		if (event.IsDeclined() || event.IsTransparent() || (event.IsAllDay() && !options.AllDayBusy)) {
+			continue
+		}
+		start, err := event.StartTime(options.Location)
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid start of event '%s': %w", event.Id, err)
+		}
+		end, err := event.EndTime(options.Location)
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid end of event '%s': %w", event.Id, err)
+		}
+		busy = append(busy, interval.New(start, end))
+	}
+
+	return interval.Merge(busy), nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package combaccount
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/EugeneShtoka/figoro/lib/eventsfilter"
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"github.com/EugeneShtoka/figoro/lib/gaccount"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+func TestEventsOfUninitializedAccount(t *testing.T) {
+	accounts := []gaccount.GAccount{
+		{ Name: "work", Calendars: gaccount.GCalendars{ WhiteList: []string{ "primary" } } },
+	}
+	account, err := New("figoro", accounts)
+	if (err != nil) {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	events, err := account.Events(context.Background(), eventsfilter.New())
+	if (len(events) != 0) {
+		t.Errorf("Events() = %v, want no events", events)
+	}
+	sourceErrors := SourceErrors(err)
+	if (len(sourceErrors) != 1 || sourceErrors[0].Account != "work" || !errors.Is(sourceErrors[0], gaccount.ErrNotInitialized)) {
+		t.Errorf("Events() error = %v, want a SourceError of account 'work'", err)
+	}
+}
+
+func TestSortCancelledOccurrencesByOriginalStart(t *testing.T) {
+	source := figevent.Source{ Account: "work", CalendarID: "primary" }
+	events := figevent.Wrap(source, []*calendar.Event{
+		{ Id: "late", Start: &calendar.EventDateTime{ DateTime: "2024-05-08T10:00:00Z" } },
+		{ Id: "cancelled", Status: "cancelled", RecurringEventId: "series", OriginalStartTime: &calendar.EventDateTime{ DateTime: "2024-05-07T10:00:00Z" } },
+		{ Id: "early", Start: &calendar.EventDateTime{ DateTime: "2024-05-06T10:00:00Z" } },
+	})
+
+	sortEventsByStartTime(events)
+	for i, want := range []string{ "early", "cancelled", "late" } {
+		if (events[i].Id != want) {
+			t.Errorf("event %d is '%s', want '%s'", i, events[i].Id, want)
+		}
+	}
+}
+
+// pagedCalendars serves every calendar as pages of events ordered by start, counting the requested pages
+type pagedCalendars struct {
+	mu			sync.Mutex
+	pages		map[string][][]*calendar.Event
+	requested	map[string]int
+}
+
+func (c *pagedCalendars) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	calendarId := strings.Split(strings.TrimPrefix(r.URL.Path, "/calendars/"), "/")[0]
+	c.mu.Lock()
+	page := c.requested[calendarId]
+	c.requested[calendarId]++
+	c.mu.Unlock()
+
+	response := &calendar.Events{ Items: c.pages[calendarId][page] }
+	if (page + 1 < len(c.pages[calendarId])) {
+		response.NextPageToken = "next"
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func timedEvent(id string, start string) *calendar.Event {
+	return &calendar.Event{ Id: id, Start: &calendar.EventDateTime{ DateTime: start }, End: &calendar.EventDateTime{ DateTime: start } }
+}
+
+func TestEventsMergesOrderedCalendars(t *testing.T) {
+	calendars := &pagedCalendars{
+		pages: map[string][][]*calendar.Event{
+			"team": {
+				{ timedEvent("t1", "2024-05-06T09:00:00Z"), timedEvent("t2", "2024-05-06T12:00:00Z") },
+				{ timedEvent("t3", "2024-05-07T09:00:00Z") },
+			},
+			"primary": {
+				{ timedEvent("p1", "2024-05-06T10:00:00Z"), timedEvent("p2", "2024-05-06T11:00:00Z") },
+				{ timedEvent("p3", "2024-05-08T09:00:00Z") },
+			},
+		},
+		requested: make(map[string]int),
+	}
+	server := httptest.NewServer(calendars)
+	defer server.Close()
+
+	service, err := calendar.NewService(context.Background(), option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL + "/"))
+	if (err != nil) {
+		t.Fatalf("NewService() failed: %v", err)
+	}
+	account, err := New("figoro", []gaccount.GAccount{
+		{ Name: "work", Service: service, Calendars: gaccount.GCalendars{ WhiteList: []string{ "primary", "team" } } },
+	})
+	if (err != nil) {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	filter := eventsfilter.New().ShowSingle().OrderBy("startTime").MaxResults(4)
+	events, err := account.Events(context.Background(), filter)
+	if (err != nil) {
+		t.Fatalf("Events() failed: %v", err)
+	}
+	for i, want := range []string{ "t1", "p1", "p2", "t2" } {
+		if (i >= len(events) || events[i].Id != want) {
+			t.Fatalf("Events() returned %d events, event %d is not '%s'", len(events), i, want)
+		}
+	}
+	if (len(events) != 4) {
+		t.Errorf("Events() returned %d events, want 4", len(events))
+	}
+	// the second page of primary may start before t2, the second page of team is never needed
+	if (calendars.requested["primary"] != 2 || calendars.requested["team"] != 1) {
+		t.Errorf("pages requested %v, want 2 of primary and 1 of team", calendars.requested)
+	}
+}
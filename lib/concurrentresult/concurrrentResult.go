@@ -2,47 +2,123 @@ package concurrentresult
 
 import (
 	"context"
+	"errors"
+	"sync"
 )
 
+// Task is a unit of work run by ConcurrentResult, it has to stop when ctx is done
+type Task[T any] func(ctx context.Context) (T, error)
+
+// ConcurrentResult runs tasks concurrently and collects their results and errors
 type ConcurrentResult[T any] struct {
-    resultsChannel chan T
-	errorChanel chan error
-	cancel context.CancelFunc
+	ctx			context.Context
+	cancel		context.CancelFunc
+	slots		chan struct{}
+	failFast	bool
+	// failed tells that the context was cancelled by fail fast, not by the parent context
+	failed		bool
+	wg			sync.WaitGroup
+	mu			sync.Mutex
+	results		[]T
+	done		[]bool
+	errs		[]error
 }
 
-func New[T any](ctx context.Context) *ConcurrentResult[T] {
-	resultsChannel := make(chan T)
-	errorChanel := make(chan error)
-	_, cancel := context.WithCancel(ctx)
+// New creates ConcurrentResult running at most limit tasks at once, zero or less means no limit
+func New[T any](ctx context.Context, limit int) *ConcurrentResult[T] {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var slots chan struct{}
+	if (limit > 0) {
+		slots = make(chan struct{}, limit)
+	}
 
 	return &ConcurrentResult[T]{
-		resultsChannel,
-		errorChanel,
-		cancel,
+		ctx: ctx,
+		cancel: cancel,
+		slots: slots,
 	}
 }
 
-func (this *ConcurrentResult[T]) SendResult(result T) {
-	this.resultsChannel <- result
+// FailFast cancels the context of all tasks as soon as one of them fails
+func (this *ConcurrentResult[T]) FailFast() *ConcurrentResult[T] {
+	this.failFast = true
+	return this
+}
+
+// Go starts the task, it waits for a free slot when the number of running tasks is limited
+func (this *ConcurrentResult[T]) Go(task Task[T]) {
+	this.mu.Lock()
+	index := len(this.results)
+	var zero T
+	this.results = append(this.results, zero)
+	this.done = append(this.done, false)
+	this.mu.Unlock()
+
+	this.wg.Add(1)
+	go func() {
+		defer this.wg.Done()
+
+		if (this.slots != nil) {
+			select {
+			case this.slots <- struct{}{}:
+				defer func() { <-this.slots }()
+			case <-this.ctx.Done():
+				this.fail(this.ctx.Err())
+				return
+			}
+		}
+
+		result, err := task(this.ctx)
+		if (err != nil) {
+			this.fail(err)
+			return
+		}
+
+		this.mu.Lock()
+		this.results[index] = result
+		this.done[index] = true
+		this.mu.Unlock()
+	}()
 }
 
-func (this *ConcurrentResult[T]) SendError(err error) {
-	this.errorChanel <- err
+func (this *ConcurrentResult[T]) fail(err error) {
+	this.mu.Lock()
+	// tasks stopped by fail fast only echo the error which caused it,
+	// cancellation of the parent context is always reported
+	if (this.failed && errors.Is(err, context.Canceled)) {
+		this.mu.Unlock()
+		return
+	}
+	this.errs = append(this.errs, err)
+	if (this.failFast) {
+		this.failed = true
+	}
+	this.mu.Unlock()
+
+	if (this.failFast) {
+		this.cancel()
+	}
 }
 
+// Cancel stops all running tasks through their context
 func (this *ConcurrentResult[T]) Cancel() {
 	this.cancel()
 }
 
-func (this *ConcurrentResult[T]) Results(resCount int) ([]T, error) {
-	results := make([]T, resCount)
-	for i := 0; i < resCount; i++ {
-		select {
-			case result := <-this.resultsChannel:
-				results[i] = result
-			case err := <-this.errorChanel:
-				return make([]T, resCount), err
+// Wait blocks until all tasks finish. It returns results of successful tasks in the order they were started,
+// together with errors of failed tasks joined by errors.Join, so partial results are available on failures
+func (this *ConcurrentResult[T]) Wait() ([]T, error) {
+	this.wg.Wait()
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	results := make([]T, 0, len(this.results))
+	for i, result := range this.results {
+		if (this.done[i]) {
+			results = append(results, result)
 		}
 	}
-	return results, nil
-}
\ No newline at end of file
+	return results, errors.Join(this.errs...)
+}
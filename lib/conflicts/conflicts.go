@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package conflicts
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"github.com/EugeneShtoka/figoro/lib/interval"
+)
+
+// Options select which events can be in conflict
+type Options struct {
+	IgnoreDeclined		bool
+	IgnoreTransparent	bool
+	IgnoreAllDay		bool
+	// AcrossAccounts reports only conflicts between events of different accounts
+	AcrossAccounts		bool
+	// Location is used for the dates of all-day events
+	Location			*time.Location
+}
+
+// Conflict is a pair of events whose times overlap
+type Conflict struct {
+	First		*figevent.Event		`json:"first"`
+	Second		*figevent.Event		`json:"second"`
+	Overlap		interval.Interval	`json:"overlap"`
+}
+
+type timedEvent struct {
+	event	*figevent.Event
+	span	interval.Interval
+}
+
+// Find reports every pair of overlapping events. The same event seen in several calendars,
+// e.g. an invitation accepted from two accounts, shares its iCalUID and is not a conflict
+func Find(events []*figevent.Event, options *Options) ([]Conflict, error) {
+	timed := make([]timedEvent, 0, len(events))
+	for _, event := range events {
+		if (event.Status == "cancelled" ||
+			(options.IgnoreDeclined && event.IsDeclined()) ||
+			(options.IgnoreTransparent && event.IsTransparent()) ||
+			(options.IgnoreAllDay && event.IsAllDay())) {
+			continue
+		}
+		start, err := event.StartTime(options.Location)
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid start of event '%s': %w", event.Id, err)
+		}
+		end, err := event.EndTime(options.Location)
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid end of event '%s': %w", event.Id, err)
+		}
+		timed = append(timed, timedEvent{ event: event, span: interval.New(start, end) })
+	}
+	slices.SortStableFunc(timed, func(a, b timedEvent) int { return a.span.Start.Compare(b.span.Start) })
+
+	conflicts := make([]Conflict, 0)
+	active := make([]timedEvent, 0)
+	for _, current := range timed {
+		active = slices.DeleteFunc(active, func(other timedEvent) bool { return !other.span.End.After(current.span.Start) })
+		for _, other := range active {
+			if (!other.span.Overlaps(current.span) || isSameEvent(other.event, current.event)) {
+				continue
+			}
+			if (options.AcrossAccounts && other.event.Account == current.event.Account) {
+				continue
+			}
+			conflicts = append(conflicts, Conflict{
+				First: other.event,
+				Second: current.event,
+				Overlap: interval.New(current.span.Start, minTime(other.span.End, current.span.End)),
+			})
+		}
+		active = append(active, current)
+	}
+	return conflicts, nil
+}
+
+func isSameEvent(a *figevent.Event, b *figevent.Event) bool {
+	return a.ICalUID != "" && a.ICalUID == b.ICalUID && originalStart(a) == originalStart(b)
+}
+
+// originalStart tells apart instances of a recurring event, which share iCalUID
+func originalStart(event *figevent.Event) string {
+	if (event.OriginalStartTime == nil) {
+		return ""
+	}
+	return event.OriginalStartTime.DateTime + event.OriginalStartTime.Date
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if (a.Before(b)) {
+		return a
+	}
+	return b
+}
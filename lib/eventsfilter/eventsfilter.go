@@ -17,8 +17,11 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package eventsfilter
 
 import (
-	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/EugeneShtoka/figoro/lib/figevent"
 	"google.golang.org/api/calendar/v3"
 )
 
@@ -30,6 +33,8 @@ type EventsFilter struct {
 	orderBy			*string
 	single			bool
 	deleted			bool
+	// occurrences keeps cancelled occurrences of recurring events, which exports turn into exceptions of their series
+	occurrences		bool
 }
 
 func New() *EventsFilter {
@@ -41,6 +46,7 @@ func New() *EventsFilter {
 		orderBy: nil,
 		single: false,
 		deleted: false,
+		occurrences: false,
 	}
 }
 
@@ -88,8 +94,18 @@ func (ef *EventsFilter) ShowDeleted () *EventsFilter {
 	return ef
 }
 
+// ShowCancelledOccurrences keeps the cancelled occurrences which Google lists for recurring events
+// that are not expanded. They have no start and end of their own, only the original start
+func (ef *EventsFilter) ShowCancelledOccurrences () *EventsFilter {
+	ef.occurrences = true
+	return ef
+}
+
+func (ef *EventsFilter) IsSingle () bool {
+	return ef.single
+}
+
 func (ef *EventsFilter) IsOrderedByStartTime () bool {
-	fmt.Printf("ef.orderBy: %v\n", ef.orderBy != nil)
 	return (ef.orderBy != nil && *ef.orderBy == "startTime")
 }
 
@@ -121,4 +137,69 @@ func (ef *EventsFilter) Apply (listCall *calendar.EventsListCall) *calendar.Even
 	}
 
 	return listCall
-}
\ No newline at end of file
+}
+
+// Hides reports whether the event is a cancelled occurrence which the filter leaves out, even though
+// the API lists it. Lists of events have nothing to show for them, so they are dropped before maxResults is applied
+func (ef *EventsFilter) Hides (event *calendar.Event) bool {
+	return isCancelledOccurrence(event) && !ef.deleted && !ef.occurrences
+}
+
+// Match reports whether the event passes the filter when it is applied locally, e.g. to cached events,
+// mirroring what the API does with the same parameters
+func (ef *EventsFilter) Match (event *calendar.Event) bool {
+	// expanded instances have no exceptions, so cancelled occurrences are dropped there
+	if (!ef.deleted && event.Status == "cancelled" && (ef.single || !isCancelledOccurrence(event))) {
+		return false
+	}
+	if (ef.Hides(event)) {
+		return false
+	}
+
+	if (ef.eventTypes != nil) {
+		eventType := event.EventType
+		if (eventType == "") {
+			eventType = "default"
+		}
+		if (!slices.Contains(strings.Split(*ef.eventTypes, ","), eventType)) {
+			return false
+		}
+	}
+
+	// cancelled occurrences only keep the original start, it stands for both ends
+	eventStart, eventEnd := event.Start, event.End
+	if (eventStart == nil && event.OriginalStartTime != nil) {
+		eventStart, eventEnd = event.OriginalStartTime, event.OriginalStartTime
+	}
+
+	if (ef.maxStartTime != nil && eventStart != nil) {
+		maxStart, err := time.Parse(time.RFC3339, *ef.maxStartTime)
+		start, startErr := figevent.ParseTime(eventStart, time.Local)
+		if (err == nil && startErr == nil && !start.Before(maxStart)) {
+			return false
+		}
+	}
+
+	if (ef.minEndTime != nil && eventEnd != nil) {
+		minEnd, err := time.Parse(time.RFC3339, *ef.minEndTime)
+		end, endErr := figevent.ParseTime(eventEnd, time.Local)
+		// recurring masters span all of their instances, so the end of the first one says nothing
+		if (len(event.Recurrence) > 0) {
+			var bounded bool
+			end, bounded = seriesEnd(event)
+			if (!bounded) {
+				return true
+			}
+		}
+		if (err == nil && endErr == nil && !end.After(minEnd)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isCancelledOccurrence tells whether the event is a stub of a cancelled occurrence of a recurring event
+func isCancelledOccurrence(event *calendar.Event) bool {
+	return event.Status == "cancelled" && event.RecurringEventId != "" && event.OriginalStartTime != nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package eventsfilter
+
+import (
+	"testing"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func recurring(start string, end string, recurrence ...string) *calendar.Event {
+	return &calendar.Event{
+		Start: &calendar.EventDateTime{ DateTime: start, TimeZone: "UTC" },
+		End: &calendar.EventDateTime{ DateTime: end, TimeZone: "UTC" },
+		Recurrence: recurrence,
+	}
+}
+
+func TestMatchRecurringMasters(t *testing.T) {
+	filter := New().MinEndTime("2024-06-01T00:00:00Z")
+	tests := []struct {
+		name	string
+		event	*calendar.Event
+		want	bool
+	}{
+		{ "endless", recurring("2020-01-06T10:00:00Z", "2020-01-06T11:00:00Z", "RRULE:FREQ=WEEKLY"), true },
+		{ "ended by until", recurring("2020-01-06T10:00:00Z", "2020-01-06T11:00:00Z", "RRULE:FREQ=WEEKLY;UNTIL=20201231T235959Z"), false },
+		{ "running by until", recurring("2020-01-06T10:00:00Z", "2020-01-06T11:00:00Z", "RRULE:FREQ=DAILY;UNTIL=20250101T000000Z"), true },
+		{ "until date covers its day", recurring("2024-05-01T10:00:00Z", "2024-05-01T11:00:00Z", "RRULE:FREQ=DAILY;UNTIL=20240531"), true },
+		{ "ended by count", recurring("2020-01-06T10:00:00Z", "2020-01-06T11:00:00Z", "RRULE:FREQ=DAILY;COUNT=10"), false },
+		{ "running by count", recurring("2024-05-01T10:00:00Z", "2024-05-01T11:00:00Z", "RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3"), true },
+		{ "last instance ends on the limit", recurring("2024-05-31T23:00:00Z", "2024-06-01T00:00:00Z", "RRULE:FREQ=DAILY;COUNT=1"), false },
+		{ "count with skipping parts", recurring("2020-01-06T10:00:00Z", "2020-01-06T11:00:00Z", "RRULE:FREQ=DAILY;BYMONTH=1;COUNT=10"), true },
+		{ "extra dates", recurring("2020-01-06T10:00:00Z", "2020-01-06T11:00:00Z", "RRULE:FREQ=DAILY;COUNT=1", "RDATE:20250101T100000Z"), true },
+		{ "monthly on the 31st", recurring("2024-01-31T10:00:00Z", "2024-01-31T11:00:00Z", "RRULE:FREQ=MONTHLY;COUNT=4"), true },
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := filter.Match(test.event); (got != test.want) {
+				t.Errorf("Match() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatchCancelled(t *testing.T) {
+	exception := &calendar.Event{
+		Status: "cancelled",
+		RecurringEventId: "series",
+		OriginalStartTime: &calendar.EventDateTime{ DateTime: "2024-07-01T10:00:00Z" },
+	}
+	deleted := &calendar.Event{ Status: "cancelled" }
+
+	if (New().Match(exception) || !New().Hides(exception)) {
+		t.Errorf("cancelled exceptions of series have to be dropped from lists")
+	}
+	if (!New().ShowCancelledOccurrences().Match(exception)) {
+		t.Errorf("cancelled exceptions of series have to be kept with ShowCancelledOccurrences")
+	}
+	if (New().ShowCancelledOccurrences().MaxStartTime("2024-06-01T00:00:00Z").Match(exception)) {
+		t.Errorf("cancelled exceptions after the period have to be dropped")
+	}
+	if (New().ShowCancelledOccurrences().MinEndTime("2024-08-01T00:00:00Z").Match(exception)) {
+		t.Errorf("cancelled exceptions before the period have to be dropped")
+	}
+	if (New().ShowCancelledOccurrences().ShowSingle().Match(exception)) {
+		t.Errorf("cancelled instances have to be dropped when events are expanded")
+	}
+	if (New().Match(deleted)) {
+		t.Errorf("deleted events have to be dropped")
+	}
+	if (!New().ShowDeleted().Match(deleted)) {
+		t.Errorf("deleted events have to be kept with ShowDeleted")
+	}
+}
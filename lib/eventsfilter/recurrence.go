@@ -0,0 +1,155 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package eventsfilter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"google.golang.org/api/calendar/v3"
+)
+
+var (
+	untilDateLayout = "20060102"
+	untilLayouts = []string{"20060102T150405Z", "20060102T150405", untilDateLayout}
+)
+
+// seriesEnd returns a time by which every instance of a recurring event has ended.
+// It returns false for endless series and for rules too complex to bound without expanding them
+func seriesEnd(event *calendar.Event) (time.Time, bool) {
+	location := time.Local
+	if (event.Start != nil && event.Start.TimeZone != "") {
+		if loaded, err := time.LoadLocation(event.Start.TimeZone); (err == nil) {
+			location = loaded
+		}
+	}
+	start, err := figevent.ParseTime(event.Start, location)
+	if (err != nil) {
+		return time.Time{}, false
+	}
+	end, err := figevent.ParseTime(event.End, location)
+	if (err != nil) {
+		return time.Time{}, false
+	}
+	duration := end.Sub(start)
+
+	var last time.Time
+	found := false
+	for _, line := range event.Recurrence {
+		name, value, _ := strings.Cut(line, ":")
+		name, _, _ = strings.Cut(name, ";")
+		switch strings.ToUpper(name) {
+		case "RDATE":
+			// extra dates may come after the end of the rule
+			return time.Time{}, false
+		case "RRULE":
+			lastStart, bounded := lastRuleStart(value, start, location)
+			if (!bounded) {
+				return time.Time{}, false
+			}
+			if (!found || lastStart.After(last)) {
+				last = lastStart
+			}
+			found = true
+		}
+	}
+	if (!found) {
+		return time.Time{}, false
+	}
+	return last.Add(duration), true
+}
+
+// lastRuleStart returns a time no earlier than the start of the last instance generated by the rule
+func lastRuleStart(rule string, start time.Time, location *time.Location) (time.Time, bool) {
+	parts := make(map[string]string)
+	for _, part := range strings.Split(strings.ToUpper(rule), ";") {
+		key, value, _ := strings.Cut(part, "=")
+		parts[key] = value
+	}
+
+	if until, ok := parts["UNTIL"]; (ok) {
+		for _, layout := range untilLayouts {
+			parsed, err := time.ParseInLocation(layout, until, location)
+			if (err == nil && layout == untilDateLayout) {
+				// instances may start at any time of the last day
+				return parsed.AddDate(0, 0, 1), true
+			}
+			if (err == nil) {
+				return parsed, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	count, err := strconv.Atoi(parts["COUNT"])
+	if (err != nil || count < 1) {
+		return time.Time{}, false
+	}
+	interval := 1
+	if (parts["INTERVAL"] != "") {
+		interval, err = strconv.Atoi(parts["INTERVAL"])
+		if (err != nil || interval < 1) {
+			return time.Time{}, false
+		}
+	}
+	for key := range parts {
+		switch key {
+		case "FREQ", "COUNT", "INTERVAL", "WKST":
+		case "BYDAY":
+			// every week has an instance, but months may have none of '5FR'
+			if (parts["FREQ"] != "WEEKLY" && parts["FREQ"] != "DAILY") {
+				return time.Time{}, false
+			}
+		default:
+			// other BY parts can skip whole periods, so the count does not bound the series
+			return time.Time{}, false
+		}
+	}
+
+	steps := (count - 1) * interval
+	switch parts["FREQ"] {
+	case "SECONDLY":
+		return start.Add(time.Duration(steps) * time.Second), true
+	case "MINUTELY":
+		return start.Add(time.Duration(steps) * time.Minute), true
+	case "HOURLY":
+		return start.Add(time.Duration(steps) * time.Hour), true
+	case "DAILY":
+		if (parts["BYDAY"] != "") {
+			// days filtered by weekday leave at least one instance per interval of weeks
+			return start.AddDate(0, 0, 7 * (steps + interval)), true
+		}
+		return start.AddDate(0, 0, steps), true
+	case "WEEKLY":
+		return start.AddDate(0, 0, 7 * (steps + 1)), true
+	case "MONTHLY":
+		// months without the day of the start, like the 31st, are skipped
+		if (start.Day() > 28) {
+			steps *= 2
+		}
+		return start.AddDate(0, steps, 0), true
+	case "YEARLY":
+		// February 29th comes at most every 8 years
+		if (start.Month() == time.February && start.Day() == 29) {
+			steps *= 8
+		}
+		return start.AddDate(steps, 0, 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,475 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package eventsrender
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"github.com/EugeneShtoka/figoro/lib/ics"
+	"google.golang.org/api/calendar/v3"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	Formats = []string{"table", "agenda", "json", "jsonl", "csv", "yaml", "ics"}
+	DefaultColumns = []string{"start", "end", "summary", "account", "calendar", "location"}
+
+	humanDateTimeLayout = "2006-01-02 15:04"
+	humanTimeLayout = "15:04"
+	agendaDayLayout = "Mon, 02 Jan 2006"
+	columnGap = "  "
+	ellipsis = "…"
+	// columns which give away width first when a table does not fit into the terminal
+	flexibleColumns = []string{"summary", "description", "location", "attendees", "link", "calendar", "calendarId"}
+	minFlexibleWidth = 10
+)
+
+// column extracts a value from an event, human is set for terminal oriented formats
+type column func(event *figevent.Event, human bool) string
+
+var columns = map[string]column{
+	"account": func(event *figevent.Event, human bool) string { return event.Account },
+	"calendar": func(event *figevent.Event, human bool) string { return event.CalendarName() },
+	"calendarId": func(event *figevent.Event, human bool) string { return event.CalendarID },
+	"color": func(event *figevent.Event, human bool) string { return event.CalendarColor },
+	"id": func(event *figevent.Event, human bool) string { return event.Id },
+	"start": func(event *figevent.Event, human bool) string { return formatEventTime(event.Start, human) },
+	"end": func(event *figevent.Event, human bool) string { return formatEventTime(event.End, human) },
+	"summary": func(event *figevent.Event, human bool) string { return event.Summary },
+	"location": func(event *figevent.Event, human bool) string { return event.Location },
+	"description": func(event *figevent.Event, human bool) string { return event.Description },
+	"status": func(event *figevent.Event, human bool) string { return event.Status },
+	"type": func(event *figevent.Event, human bool) string { return event.EventType },
+	"link": func(event *figevent.Event, human bool) string { return event.HtmlLink },
+	"organizer": func(event *figevent.Event, human bool) string {
+		if (event.Organizer == nil) {
+			return ""
+		}
+		return event.Organizer.Email
+	},
+	"attendees": func(event *figevent.Event, human bool) string {
+		emails := make([]string, len(event.Attendees))
+		for i, attendee := range event.Attendees {
+			emails[i] = attendee.Email
+		}
+		return strings.Join(emails, ",")
+	},
+}
+
+// ColumnNames returns names of all supported columns
+func ColumnNames() []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Warning describes an account or calendar whose events are missing from partial output
+type Warning struct {
+	Account		string	`json:"account"`
+	Calendar	string	`json:"calendar,omitempty"`
+	Error		string	`json:"error"`
+}
+
+type Renderer struct {
+	Format		string
+	Columns		[]string
+	// Width limits the width of table output, zero means unlimited
+	Width		int
+	// Full makes machine readable formats output whole events instead of selected columns
+	Full		bool
+	// Partial makes json output an object with events and warnings instead of a plain list of events
+	Partial		bool
+	Warnings	[]Warning
+	Template	*template.Template
+}
+
+// New validates the format and columns. When columns are empty, machine readable formats output whole events
+func New(format string, selectedColumns []string, width int) (*Renderer, error) {
+	if (!slices.Contains(Formats, format)) {
+		return nil, fmt.Errorf("unknown output format '%s', expected one of %v", format, Formats)
+	}
+
+	full := len(selectedColumns) == 0
+	if (full) {
+		selectedColumns = DefaultColumns
+	}
+	for _, name := range selectedColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("unknown column '%s', expected one of %v", name, ColumnNames())
+		}
+	}
+
+	return &Renderer{
+		Format: format,
+		Columns: selectedColumns,
+		Width: width,
+		Full: full,
+	}, nil
+}
+
+func (r *Renderer) Render(w io.Writer, events []*figevent.Event) error {
+	switch r.Format {
+	case "table":
+		return r.renderTable(w, events)
+	case "agenda":
+		return r.renderAgenda(w, events)
+	case "json":
+		return r.renderJson(w, events)
+	case "jsonl":
+		return r.renderJsonLines(w, events)
+	case "csv":
+		return r.renderCsv(w, events)
+	case "yaml":
+		return r.renderYaml(w, events)
+	case "ics":
+		return ics.NewEncoder(w).Encode(events)
+	case "template":
+		return r.renderTemplate(w, events)
+	}
+	return fmt.Errorf("unknown output format '%s'", r.Format)
+}
+
+func (r *Renderer) row(event *figevent.Event, human bool) []string {
+	values := make([]string, len(r.Columns))
+	for i, name := range r.Columns {
+		values[i] = columns[name](event, human)
+		if (human) {
+			values[i] = strings.Join(strings.Fields(values[i]), " ")
+		}
+	}
+	return values
+}
+
+func (r *Renderer) record(event *figevent.Event) (any, error) {
+	if (!r.Full) {
+		values := r.row(event, false)
+		record := make(map[string]string, len(r.Columns))
+		for i, name := range r.Columns {
+			record[name] = values[i]
+		}
+		return record, nil
+	}
+
+	// round trip through json, so that field names match the Calendar API in every format
+	// and the source of the event is added next to them
+	data, err := json.Marshal(event)
+	if (err != nil) {
+		return nil, err
+	}
+	var record map[string]any
+	err = json.Unmarshal(data, &record)
+	return record, err
+}
+
+func (r *Renderer) records(events []*figevent.Event) ([]any, error) {
+	records := make([]any, len(events))
+	for i, event := range events {
+		record, err := r.record(event)
+		if (err != nil) {
+			return nil, fmt.Errorf("failed to convert event '%s': %w", event.Id, err)
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+func (r *Renderer) renderJson(w io.Writer, events []*figevent.Event) error {
+	records, err := r.records(events)
+	if (err != nil) {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if (r.Partial) {
+		warnings := r.Warnings
+		if (warnings == nil) {
+			warnings = []Warning{}
+		}
+		return encoder.Encode(map[string]any{ "events": records, "warnings": warnings })
+	}
+	return encoder.Encode(records)
+}
+
+func (r *Renderer) renderJsonLines(w io.Writer, events []*figevent.Event) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, event := range events {
+		record, err := r.record(event)
+		if (err != nil) {
+			return fmt.Errorf("failed to convert event '%s': %w", event.Id, err)
+		}
+		err = encoder.Encode(record)
+		if (err != nil) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Renderer) renderYaml(w io.Writer, events []*figevent.Event) error {
+	records, err := r.records(events)
+	if (err != nil) {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	err = encoder.Encode(records)
+	if (err != nil) {
+		return err
+	}
+	return encoder.Close()
+}
+
+func (r *Renderer) renderCsv(w io.Writer, events []*figevent.Event) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(r.Columns)
+	if (err != nil) {
+		return err
+	}
+	for _, event := range withStart(events) {
+		err = writer.Write(r.row(event, false))
+		if (err != nil) {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (r *Renderer) renderTable(w io.Writer, events []*figevent.Event) error {
+	rows := make([][]string, 0, len(events) + 1)
+	rows = append(rows, headers(r.Columns))
+	for _, event := range withStart(events) {
+		rows = append(rows, r.row(event, true))
+	}
+
+	widths := r.fitWidths(rows)
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			cells[i] = pad(truncate(value, widths[i]), widths[i])
+		}
+		_, err := fmt.Fprintln(w, strings.TrimRight(strings.Join(cells, columnGap), " "))
+		if (err != nil) {
+			return err
+		}
+	}
+	return nil
+}
+
+// agendaEntry is an event shown under one day of the agenda, events spanning several days have one entry per day
+type agendaEntry struct {
+	day		time.Time
+	start	time.Time
+	label	string
+	event	*figevent.Event
+}
+
+// agendaEntries spreads events over the days they take and sorts them by day and start,
+// whatever the order of events is. Events without a start, like cancelled occurrences, are left out
+func agendaEntries(events []*figevent.Event) []agendaEntry {
+	entries := make([]agendaEntry, 0, len(events))
+	for _, event := range events {
+		start, ok := parseEventTime(event.Start)
+		if (!ok) {
+			continue
+		}
+		end, ok := parseEventTime(event.End)
+		if (!ok || !end.After(start)) {
+			end = start
+		}
+
+		firstDay := startOfDay(start)
+		// the end is exclusive, so events ending at midnight do not show on the next day
+		lastDay := firstDay
+		if (end.After(start)) {
+			lastDay = startOfDay(end.Add(-time.Nanosecond))
+		}
+		if (firstDay.Equal(lastDay)) {
+			entries = append(entries, agendaEntry{ day: firstDay, start: start, label: agendaTimeRange(event), event: event })
+			continue
+		}
+
+		allDay := (event.Start.DateTime == "")
+		for day := firstDay; !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+			label := "all day"
+			switch {
+			case (allDay):
+			case (day.Equal(firstDay)):
+				label = fmt.Sprintf("from %s", start.Format(humanTimeLayout))
+			case (day.Equal(lastDay)):
+				label = fmt.Sprintf("until %s", end.Format(humanTimeLayout))
+			}
+			entryStart := day
+			if (day.Equal(firstDay)) {
+				entryStart = start
+			}
+			entries = append(entries, agendaEntry{ day: day, start: entryStart, label: label, event: event })
+		}
+	}
+
+	slices.SortStableFunc(entries, func(a, b agendaEntry) int {
+		if compared := a.start.Compare(b.start); (compared != 0) {
+			return compared
+		}
+		return strings.Compare(a.event.Id, b.event.Id)
+	})
+	return entries
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func (r *Renderer) renderAgenda(w io.Writer, events []*figevent.Event) error {
+	var day time.Time
+	for i, entry := range agendaEntries(events) {
+		if (i == 0 || !entry.day.Equal(day)) {
+			if (i > 0) {
+				fmt.Fprintln(w)
+			}
+			day = entry.day
+			fmt.Fprintln(w, day.Format(agendaDayLayout))
+		}
+
+		values := make([]string, 0, len(r.Columns))
+		for i, value := range r.row(entry.event, true) {
+			if (r.Columns[i] != "start" && r.Columns[i] != "end" && value != "") {
+				values = append(values, value)
+			}
+		}
+		line := fmt.Sprintf("  %-11s  %s", entry.label, strings.Join(values, columnGap))
+		if (r.Width > 0) {
+			line = truncate(line, r.Width)
+		}
+		_, err := fmt.Fprintln(w, strings.TrimRight(line, " "))
+		if (err != nil) {
+			return err
+		}
+	}
+	return nil
+}
+
+// fitWidths returns the natural width of each column, shrinking flexible columns to fit into r.Width
+func (r *Renderer) fitWidths(rows [][]string) []int {
+	widths := make([]int, len(r.Columns))
+	for _, row := range rows {
+		for i, value := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(value))
+		}
+	}
+	if (r.Width <= 0) {
+		return widths
+	}
+
+	total := len(columnGap) * (len(widths) - 1)
+	for _, width := range widths {
+		total += width
+	}
+	for total > r.Width {
+		widest := -1
+		for i, name := range r.Columns {
+			if (slices.Contains(flexibleColumns, name) && widths[i] > minFlexibleWidth && (widest < 0 || widths[i] > widths[widest])) {
+				widest = i
+			}
+		}
+		if (widest < 0) {
+			break
+		}
+		shrink := min(total - r.Width, widths[widest] - minFlexibleWidth)
+		widths[widest] -= shrink
+		total -= shrink
+	}
+	return widths
+}
+
+func headers(names []string) []string {
+	headers := make([]string, len(names))
+	for i, name := range names {
+		headers[i] = strings.ToUpper(name)
+	}
+	return headers
+}
+
+func truncate(value string, width int) string {
+	if (utf8.RuneCountInString(value) <= width) {
+		return value
+	}
+	if (width <= 0) {
+		return ""
+	}
+	return string([]rune(value)[:width - 1]) + ellipsis
+}
+
+func pad(value string, width int) string {
+	return value + strings.Repeat(" ", max(0, width - utf8.RuneCountInString(value)))
+}
+
+func agendaTimeRange(event *figevent.Event) string {
+	if (event.Start == nil || event.Start.DateTime == "") {
+		return "all day"
+	}
+	start, _ := parseEventTime(event.Start)
+	end, _ := parseEventTime(event.End)
+	return fmt.Sprintf("%s-%s", start.Format(humanTimeLayout), end.Format(humanTimeLayout))
+}
+
+func formatEventTime(eventTime *calendar.EventDateTime, human bool) string {
+	if (eventTime == nil) {
+		return ""
+	}
+	if (eventTime.DateTime == "") {
+		return eventTime.Date
+	}
+	if (!human) {
+		return eventTime.DateTime
+	}
+	value, ok := parseEventTime(eventTime)
+	if (!ok) {
+		return eventTime.DateTime
+	}
+	return value.Format(humanDateTimeLayout)
+}
+
+// withStart leaves out events without a start, like cancelled occurrences, which have nothing to show in a row
+func withStart(events []*figevent.Event) []*figevent.Event {
+	kept := make([]*figevent.Event, 0, len(events))
+	for _, event := range events {
+		if _, ok := parseEventTime(event.Start); (ok) {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// parseEventTime converts timed events to local time, dates of all-day events are kept as they are
+func parseEventTime(eventTime *calendar.EventDateTime) (time.Time, bool) {
+	value, err := figevent.ParseTime(eventTime, time.Local)
+	return value.Local(), err == nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package eventsrender
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"google.golang.org/api/calendar/v3"
+)
+
+// eventsWithStub has a cancelled occurrence, which has no start, between two events
+func eventsWithStub() []*figevent.Event {
+	return figevent.Wrap(figevent.Source{ Account: "work", CalendarID: "primary" }, []*calendar.Event{
+		{ Id: "1", Summary: "Standup", Start: &calendar.EventDateTime{ Date: "2024-05-06" }, End: &calendar.EventDateTime{ Date: "2024-05-07" } },
+		{ Id: "2", Status: "cancelled", RecurringEventId: "series", OriginalStartTime: &calendar.EventDateTime{ Date: "2024-05-07" } },
+		{ Id: "3", Summary: "Review", Start: &calendar.EventDateTime{ Date: "2024-05-08" }, End: &calendar.EventDateTime{ Date: "2024-05-09" } },
+	})
+}
+
+func TestRenderSkipsEventsWithoutStart(t *testing.T) {
+	template, err := NewTemplate(`{{.Start | time "2006-01-02"}} {{.Summary}}`)
+	if (err != nil) {
+		t.Fatalf("NewTemplate() failed: %v", err)
+	}
+	csv, err := New("csv", []string{ "start", "summary" }, 0)
+	if (err != nil) {
+		t.Fatalf("New() failed: %v", err)
+	}
+	table, err := New("table", []string{ "start", "summary" }, 0)
+	if (err != nil) {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	for _, renderer := range []*Renderer{ template, csv, table } {
+		var buffer bytes.Buffer
+		err := renderer.Render(&buffer, eventsWithStub())
+		if (err != nil) {
+			t.Fatalf("Render() of %s failed: %v", renderer.Format, err)
+		}
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		want := 2
+		if (renderer.Format != "template") {
+			want++
+		}
+		if (len(lines) != want) {
+			t.Errorf("%s output has %d lines, want %d:\n%s", renderer.Format, len(lines), want, buffer.String())
+		}
+	}
+}
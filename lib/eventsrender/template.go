@@ -0,0 +1,210 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package eventsrender
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"google.golang.org/api/calendar/v3"
+)
+
+var (
+	ansiColors = map[string]string{
+		"black": "30", "red": "31", "green": "32", "yellow": "33",
+		"blue": "34", "magenta": "35", "cyan": "36", "white": "37",
+		"bold": "1", "dim": "2", "underline": "4",
+	}
+	ansiReset = "\033[0m"
+)
+
+// templateFuncs are helpers available in --template, e.g. {{.Start | time "15:04"}} {{.Summary | truncate 20}}
+var templateFuncs = template.FuncMap{
+	"time": formatTime,
+	"timeIn": formatTimeIn,
+	"duration": eventDuration,
+	"until": untilStart,
+	"truncate": truncateText,
+	"color": colorText,
+	"attendees": joinAttendees,
+	"join": strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"default": defaultText,
+}
+
+// NewTemplate parses a Go template which is executed once per event with a start, output of every event ends with a newline
+func NewTemplate(text string) (*Renderer, error) {
+	tmpl, err := template.New("event").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return &Renderer{ Format: "template", Template: tmpl }, nil
+}
+
+func (r *Renderer) renderTemplate(w io.Writer, events []*figevent.Event) error {
+	for _, event := range withStart(events) {
+		var builder strings.Builder
+		err := r.Template.Execute(&builder, event)
+		if (err != nil) {
+			return fmt.Errorf("failed to execute template for event '%s': %w", event.Id, err)
+		}
+		line := builder.String()
+		if (!strings.HasSuffix(line, "\n")) {
+			line += "\n"
+		}
+		_, err = io.WriteString(w, line)
+		if (err != nil) {
+			return err
+		}
+	}
+	return nil
+}
+
+// toTime accepts event times as well as plain times, so helpers can be chained
+func toTime(value any) (time.Time, bool, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, false, nil
+	case *calendar.EventDateTime:
+		if (v == nil) {
+			return time.Time{}, false, fmt.Errorf("event time is not set")
+		}
+		parsed, ok := parseEventTime(v)
+		if (!ok) {
+			return time.Time{}, false, fmt.Errorf("invalid event time: %v", v)
+		}
+		return parsed, v.DateTime == "", nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, v)
+		return parsed, false, err
+	}
+	return time.Time{}, false, fmt.Errorf("expected time, got %T", value)
+}
+
+func formatTime(layout string, value any) (string, error) {
+	return formatTimeIn("Local", layout, value)
+}
+
+// formatTimeIn formats the time in the zone, dates of all-day events are not shifted between zones
+func formatTimeIn(zone string, layout string, value any) (string, error) {
+	parsed, allDay, err := toTime(value)
+	if (err != nil) {
+		return "", err
+	}
+	if (allDay) {
+		return parsed.Format(layout), nil
+	}
+	location, err := time.LoadLocation(zone)
+	if (err != nil) {
+		return "", err
+	}
+	return parsed.In(location).Format(layout), nil
+}
+
+func eventDuration(event *figevent.Event) (string, error) {
+	start, _, err := toTime(event.Start)
+	if (err != nil) {
+		return "", err
+	}
+	end, _, err := toTime(event.End)
+	if (err != nil) {
+		return "", err
+	}
+	return ShortDuration(end.Sub(start)), nil
+}
+
+// untilStart returns the time left until the event starts, or an empty string if it already started
+func untilStart(event *figevent.Event) (string, error) {
+	start, _, err := toTime(event.Start)
+	if (err != nil) {
+		return "", err
+	}
+	left := time.Until(start)
+	if (left <= 0) {
+		return "", nil
+	}
+	return ShortDuration(left), nil
+}
+
+// ShortDuration formats durations the way people write them: 45m, 1h30m, 2d
+func ShortDuration(duration time.Duration) string {
+	duration = duration.Round(time.Minute)
+	days := int(duration / (24 * time.Hour))
+	hours := int(duration % (24 * time.Hour) / time.Hour)
+	minutes := int(duration % time.Hour / time.Minute)
+
+	var builder strings.Builder
+	if (days > 0) {
+		builder.WriteString(strconv.Itoa(days) + "d")
+	}
+	if (hours > 0) {
+		builder.WriteString(strconv.Itoa(hours) + "h")
+	}
+	if (minutes > 0 || builder.Len() == 0) {
+		builder.WriteString(strconv.Itoa(minutes) + "m")
+	}
+	return builder.String()
+}
+
+func truncateText(width int, text string) string {
+	if (utf8.RuneCountInString(text) <= width) {
+		return text
+	}
+	return truncate(text, width)
+}
+
+// colorText wraps text into ANSI escape codes, color is a name like "red" or a hex color like the calendar colors
+func colorText(color string, text string) string {
+	if (strings.HasPrefix(color, "#") && len(color) == 7) {
+		rgb, err := strconv.ParseUint(color[1:], 16, 32)
+		if (err != nil) {
+			return text
+		}
+		return fmt.Sprintf("\033[38;2;%d;%d;%dm%s%s", rgb >> 16, rgb >> 8 & 0xff, rgb & 0xff, text, ansiReset)
+	}
+	code, ok := ansiColors[color]
+	if (!ok) {
+		return text
+	}
+	return fmt.Sprintf("\033[%sm%s%s", code, text, ansiReset)
+}
+
+// joinAttendees joins display names of attendees, falling back to their emails
+func joinAttendees(separator string, event *figevent.Event) string {
+	names := make([]string, len(event.Attendees))
+	for i, attendee := range event.Attendees {
+		names[i] = attendee.DisplayName
+		if (names[i] == "") {
+			names[i] = attendee.Email
+		}
+	}
+	return strings.Join(names, separator)
+}
+
+func defaultText(fallback string, text string) string {
+	if (text == "") {
+		return fallback
+	}
+	return text
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package eventstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// CalendarCache is the locally stored copy of one calendar of one account
+type CalendarCache struct {
+	SyncToken	string
+	Synced		time.Time
+	Summary		string
+	Color		string
+	Events		map[string]*calendar.Event
+}
+
+// Store keeps calendar caches on disk, one file per account and calendar
+type Store struct {
+	Dir		string
+}
+
+func New(dir string) *Store {
+	return &Store{ Dir: dir }
+}
+
+// DefaultDir returns the events cache directory inside the user cache dir (XDG_CACHE_HOME on Linux)
+func DefaultDir(serviceName string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if (err != nil) {
+		return "", fmt.Errorf("failed to resolve user cache dir: %w", err)
+	}
+	return filepath.Join(cacheDir, serviceName, "events"), nil
+}
+
+func (s *Store) path(account string, calendarId string, single bool) string {
+	name := url.PathEscape(calendarId)
+	if (single) {
+		name += ".instances"
+	}
+	return filepath.Join(s.Dir, url.PathEscape(account), name + ".json")
+}
+
+// Load returns the cached calendar, or an empty cache if the calendar was never synced
+func (s *Store) Load(account string, calendarId string, single bool) (*CalendarCache, error) {
+	cache := &CalendarCache{ Events: make(map[string]*calendar.Event) }
+
+	data, err := os.ReadFile(s.path(account, calendarId, single))
+	if (errors.Is(err, fs.ErrNotExist)) {
+		return cache, nil
+	}
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to read cache of calendar '%s': %w", calendarId, err)
+	}
+
+	err = json.Unmarshal(data, cache)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to deserialize cache of calendar '%s': %w", calendarId, err)
+	}
+	if (cache.Events == nil) {
+		cache.Events = make(map[string]*calendar.Event)
+	}
+
+	return cache, nil
+}
+
+// Save writes the cache to a temporary file and renames it, so readers never see a partial file
+func (s *Store) Save(account string, calendarId string, single bool, cache *CalendarCache) error {
+	path := s.path(account, calendarId, single)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if (err != nil) {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	data, err := json.Marshal(cache)
+	if (err != nil) {
+		return fmt.Errorf("failed to serialize cache of calendar '%s': %w", calendarId, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + ".*")
+	if (err != nil) {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if (err == nil) {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if (err != nil) {
+		return fmt.Errorf("failed to write cache of calendar '%s': %w", calendarId, err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// IsSynced reports whether the calendar was synced at least once
+func (c *CalendarCache) IsSynced() bool {
+	return !c.Synced.IsZero()
+}
+
+// IsFresh reports whether the last sync happened less than maxAge ago
+func (c *CalendarCache) IsFresh(maxAge time.Duration) bool {
+	return c.IsSynced() && time.Since(c.Synced) < maxAge
+}
+
+// Reset drops all cached events and the sync token, forcing a full sync
+func (c *CalendarCache) Reset() {
+	c.SyncToken = ""
+	c.Synced = time.Time{}
+	c.Events = make(map[string]*calendar.Event)
+}
+
+// Apply merges changed events into the cache. Deleted events only carry their id and status,
+// so known events are marked as cancelled instead of being replaced
+func (c *CalendarCache) Apply(events []*calendar.Event, syncToken string) {
+	for _, event := range events {
+		cached, ok := c.Events[event.Id]
+		if (event.Status == "cancelled" && ok) {
+			cached.Status = event.Status
+			cached.Updated = event.Updated
+			continue
+		}
+		c.Events[event.Id] = event
+	}
+
+	c.SyncToken = syncToken
+	c.Synced = time.Now()
+}
+
+// List returns all cached events
+func (c *CalendarCache) List() []*calendar.Event {
+	events := make([]*calendar.Event, 0, len(c.Events))
+	for _, event := range c.Events {
+		events = append(events, event)
+	}
+	return events
+}
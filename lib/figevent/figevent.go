@@ -0,0 +1,127 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package figevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Source identifies the account and calendar an event was read from
+type Source struct {
+	Account			string	`json:"account"`
+	CalendarID		string	`json:"calendarId"`
+	CalendarSummary	string	`json:"calendarSummary,omitempty"`
+	CalendarColor	string	`json:"calendarColor,omitempty"`
+}
+
+// Event is a Google Calendar event tagged with its source, so merged lists of events
+// from several accounts keep track of where each event came from
+type Event struct {
+	Source
+	*calendar.Event
+}
+
+func New(source Source, event *calendar.Event) *Event {
+	return &Event{ Source: source, Event: event }
+}
+
+// Wrap tags all events with the same source
+func Wrap(source Source, events []*calendar.Event) []*Event {
+	wrapped := make([]*Event, len(events))
+	for i, event := range events {
+		wrapped[i] = New(source, event)
+	}
+	return wrapped
+}
+
+// CalendarName returns the calendar summary, falling back to its id
+func (s Source) CalendarName() string {
+	if (s.CalendarSummary != "") {
+		return s.CalendarSummary
+	}
+	return s.CalendarID
+}
+
+// MarshalJSON adds the source fields next to the fields of the Calendar API event
+func (e *Event) MarshalJSON() ([]byte, error) {
+	record := make(map[string]any)
+	if (e.Event != nil) {
+		data, err := json.Marshal(e.Event)
+		if (err != nil) {
+			return nil, err
+		}
+		err = json.Unmarshal(data, &record)
+		if (err != nil) {
+			return nil, err
+		}
+	}
+
+	record["account"] = e.Account
+	record["calendarId"] = e.CalendarID
+	if (e.CalendarSummary != "") {
+		record["calendarSummary"] = e.CalendarSummary
+	}
+	if (e.CalendarColor != "") {
+		record["calendarColor"] = e.CalendarColor
+	}
+	return json.Marshal(record)
+}
+
+// IsAllDay reports whether the event has dates instead of date-times
+func (e *Event) IsAllDay() bool {
+	return e.Start != nil && e.Start.DateTime == ""
+}
+
+// IsTransparent reports whether the event is marked as free time
+func (e *Event) IsTransparent() bool {
+	return e.Transparency == "transparent"
+}
+
+// IsDeclined reports whether the owner of the calendar declined the event
+func (e *Event) IsDeclined() bool {
+	for _, attendee := range e.Attendees {
+		if (attendee.Self) {
+			return attendee.ResponseStatus == "declined"
+		}
+	}
+	return false
+}
+
+// StartTime parses the start of the event, dates of all-day events are taken in the location
+func (e *Event) StartTime(location *time.Location) (time.Time, error) {
+	return ParseTime(e.Start, location)
+}
+
+// EndTime parses the end of the event, dates of all-day events are taken in the location
+func (e *Event) EndTime(location *time.Location) (time.Time, error) {
+	return ParseTime(e.End, location)
+}
+
+// ParseTime parses date-times with their own offset and dates as midnight in the location
+func ParseTime(eventTime *calendar.EventDateTime, location *time.Location) (time.Time, error) {
+	if (eventTime == nil) {
+		return time.Time{}, fmt.Errorf("event time is not set")
+	}
+	if (eventTime.DateTime != "") {
+		return time.Parse(time.RFC3339, eventTime.DateTime)
+	}
+	return time.ParseInLocation(time.DateOnly, eventTime.Date, location)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// retryInterval is the pause between attempts to take a busy lock
+	retryInterval = 50 * time.Millisecond
+	// staleAge is the age after which a lock is considered left behind by a crashed process
+	staleAge = 30 * time.Second
+)
+
+// Lock takes an exclusive lock shared between processes by creating the file at path,
+// it waits up to timeout for other holders and returns the function releasing the lock
+func Lock(path string, timeout time.Duration) (func(), error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if (err == nil) {
+			fmt.Fprintf(file, "%d\n", os.Getpid())
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if (!errors.Is(err, os.ErrExist)) {
+			return nil, fmt.Errorf("failed to create lock '%s': %w", path, err)
+		}
+
+		info, statErr := os.Stat(path)
+		if (statErr == nil && time.Since(info.ModTime()) > staleAge) {
+			os.Remove(path)
+			continue
+		}
+		if (time.Now().After(deadline)) {
+			return nil, fmt.Errorf("timed out waiting for lock '%s'", path)
+		}
+		time.Sleep(retryInterval)
+	}
+}
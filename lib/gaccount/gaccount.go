@@ -18,26 +18,151 @@ package gaccount
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"slices"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/EugeneShtoka/figoro/lib/eventsfilter"
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"github.com/EugeneShtoka/figoro/lib/filelock"
 	"github.com/EugeneShtoka/figoro/lib/gaseed"
+	"github.com/EugeneShtoka/figoro/lib/interval"
+	"github.com/EugeneShtoka/figoro/lib/pattern"
 	"github.com/EugeneShtoka/figoro/lib/typedkeyring"
 	set "github.com/deckarep/golang-set/v2"
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
+// ErrNotInitialized is returned by requests of an account whose Init failed or was never called
+var ErrNotInitialized = errors.New("account is not initialized")
+
+// ErrSyncTokenExpired is returned by SyncEvents when Google answers 410 Gone and the calendar has to be fully synced again
+var ErrSyncTokenExpired = errors.New("sync token is no longer valid, full sync required")
+
+// maxPageSize is the largest page the Calendar API returns for events list
+const maxPageSize int64 = 2500
+
+// lockTimeout is how long a token refresh waits for another figoro process refreshing the same account
+const lockTimeout = 30 * time.Second
+
+// maxFreeBusyCalendars is the number of calendars a single FreeBusy query accepts
+const maxFreeBusyCalendars = 50
+
+// primaryCalendar is the alias Google accepts for the primary calendar of an account
+const primaryCalendar = "primary"
+
+// Calendar is an entry of the calendar list of an account
+type Calendar struct {
+	ID					string
+	Summary				string
+	// SummaryOverride is the name the user gave to a calendar shared with them
+	SummaryOverride		string
+	Color				string
+	TimeZone			string
+	AccessRole			string
+	Primary				bool
+	Hidden				bool
+	Selected			bool
+}
+
+// Name is the summary shown to the user, falling back to the ID
+func (c *Calendar) Name() string {
+	if (c.SummaryOverride != "") {
+		return c.SummaryOverride
+	}
+	if (c.Summary != "") {
+		return c.Summary
+	}
+	return c.ID
+}
+
+// IsNamed tells whether the name is the ID, the summary or the summary override of the calendar, ignoring case,
+// or the 'primary' alias of the primary calendar. Patterns and calendar flags use it alike
+func (c *Calendar) IsNamed(name string) bool {
+	return (c.Primary && strings.EqualFold(name, primaryCalendar)) || strings.EqualFold(c.ID, name) ||
+		(c.Summary != "" && strings.EqualFold(c.Summary, name)) || (c.SummaryOverride != "" && strings.EqualFold(c.SummaryOverride, name))
+}
+
+// Matches tells whether the ID, the summary or the summary override of the calendar matches the pattern,
+// literal patterns are names compared by IsNamed
+func (c *Calendar) Matches(p *pattern.Pattern) bool {
+	if (p.IsLiteral()) {
+		return c.IsNamed(p.String())
+	}
+	return p.Match(c.ID) || (c.Summary != "" && p.Match(c.Summary)) || (c.SummaryOverride != "" && p.Match(c.SummaryOverride))
+}
+
+func newCalendar(entry *calendar.CalendarListEntry) Calendar {
+	return Calendar{
+		ID: entry.Id,
+		Summary: entry.Summary,
+		SummaryOverride: entry.SummaryOverride,
+		Color: entry.BackgroundColor,
+		TimeZone: entry.TimeZone,
+		AccessRole: entry.AccessRole,
+		Primary: entry.Primary,
+		Hidden: entry.Hidden,
+		Selected: entry.Selected,
+	}
+}
+
 type GCalendars struct {
-	All 		[]string
+	All 		[]Calendar
 	WhiteList	[]string
 	BlackList	[]string
+	// Synced is the time All was last read from Google
+	Synced		time.Time
+}
+
+// CalendarChanges reports what SyncCalendars changed
+type CalendarChanges struct {
+	Added		[]Calendar
+	Removed		[]Calendar
+	// Pruned are whitelist and blacklist entries dropped because their calendars no longer exist
+	Pruned		[]string
+	// Kept are whitelist entries of calendars which no longer exist, kept because an empty whitelist means all calendars
+	Kept		[]string
+}
+
+func (c *CalendarChanges) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Pruned) == 0 && len(c.Kept) == 0
+}
+
+// IDs returns IDs of all calendars
+func (c *GCalendars) IDs() []string {
+	ids := make([]string, len(c.All))
+	for i, cal := range c.All {
+		ids[i] = cal.ID
+	}
+	return ids
+}
+
+// Find returns the calendar with the ID, or nil when the account has no such calendar
+func (c *GCalendars) Find(id string) *Calendar {
+	index := slices.IndexFunc(c.All, func(cal Calendar) bool { return cal.ID == id })
+	if (index < 0) {
+		return nil
+	}
+	return &c.All[index]
 }
 
 type GAccount struct {
 	Name 			string
 	Calendars 		GCalendars
-	Service 		*calendar.Service
+	Service 		*calendar.Service	`yaml:"-" mapstructure:"-"`
+	// initErr is why Init failed, so that requests of the account fail instead of the whole run
+	initErr			error
+	// selected narrows ResolveCalendars for a single run, it is never written to config
+	selected		func(cal Calendar) bool
 }
 
 func New(serviceName string, accountName string) (*GAccount, error) {
@@ -46,7 +171,7 @@ func New(serviceName string, accountName string) (*GAccount, error) {
 		return nil, err
 	}
 
-	calendars, err := getCalendars(service)
+	calendars, err := getCalendars(context.Background(), service)
 	if (err != nil) {
 		return nil, err
 	}
@@ -54,69 +179,426 @@ func New(serviceName string, accountName string) (*GAccount, error) {
 	return &GAccount{
 		Name: accountName,
 		Service: service,
-		Calendars: GCalendars{ All: calendars },
+		Calendars: GCalendars{ All: calendars, Synced: time.Now() },
 	}, nil
 }
 
-func (s *GAccount) SyncCalendars() (error) {
-	calendars, err := getCalendars(s.Service)
+// SyncCalendars reads the list of calendars from Google again,
+// dropping whitelist and blacklist entries of calendars which no longer exist
+func (s *GAccount) SyncCalendars(ctx context.Context) (*CalendarChanges, error) {
+	service, err := s.service()
 	if (err != nil) {
-		return fmt.Errorf("failed to sync calendars: %w", err)
+		return nil, err
+	}
+	calendars, err := getCalendars(ctx, service)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to sync calendars: %w", err)
+	}
+
+	synced := GCalendars{ All: calendars }
+	current := set.NewSet(synced.IDs()...)
+	previous := set.NewSet(s.Calendars.IDs()...)
+	changes := &CalendarChanges{
+		Added: slices.DeleteFunc(slices.Clone(synced.All), func(cal Calendar) bool { return previous.Contains(cal.ID) }),
+		Removed: slices.DeleteFunc(slices.Clone(s.Calendars.All), func(cal Calendar) bool { return current.Contains(cal.ID) }),
+	}
+
+	stale := func(id string) bool {
+		if (current.Contains(id)) {
+			return false
+		}
+		changes.Pruned = append(changes.Pruned, id)
+		return true
+	}
+	if (len(s.Calendars.WhiteList) > 0 && !current.ContainsAny(s.Calendars.WhiteList...)) {
+		// pruning every entry would silently widen the selection from the included calendars to all of them
+		changes.Kept = slices.Clone(s.Calendars.WhiteList)
+	} else {
+		s.Calendars.WhiteList = slices.DeleteFunc(s.Calendars.WhiteList, stale)
 	}
+	s.Calendars.BlackList = slices.DeleteFunc(s.Calendars.BlackList, stale)
 
 	s.Calendars.All = calendars
-	return nil
+	s.Calendars.Synced = time.Now()
+	return changes, nil
+}
+
+// IsStale tells whether the list of calendars is older than maxAge
+func (s *GAccount) IsStale(maxAge time.Duration) bool {
+	return time.Since(s.Calendars.Synced) > maxAge
 }
 
 func (s *GAccount) Init(serviceName string) (error) {
 	service, err := getService(serviceName, s.Name)
 	if (err != nil) {
+		s.initErr = err
 		return err
 	}
 
 	s.Service = service
+	s.initErr = nil
 	return nil
 }
 
-func (s *GAccount) Events(calendarId string, filter *eventsfilter.EventsFilter) ([]*calendar.Event, error) {
-	events, err := filter.Apply(s.Service.Events.List(calendarId)).Do()
+// service returns the calendar service, or the reason why the account could not be initialized
+func (s *GAccount) service() (*calendar.Service, error) {
+	if (s.Service != nil) {
+		return s.Service, nil
+	}
+	if (s.initErr != nil) {
+		return nil, fmt.Errorf("%w: %w", ErrNotInitialized, s.initErr)
+	}
+	return nil, ErrNotInitialized
+}
 
-	if err != nil {
-		return nil, err
+// Events streams events of the calendar page by page, following nextPageToken
+// until all pages are read or the max results budget of the filter is spent
+func (s *GAccount) Events(ctx context.Context, calendarId string, filter *eventsfilter.EventsFilter) iter.Seq2[*calendar.Event, error] {
+	return func(yield func(*calendar.Event, error) bool) {
+		service, err := s.service()
+		if (err != nil) {
+			yield(nil, err)
+			return
+		}
+		budget := filter.GetMaxResults()
+		var count int64
+		pageToken := ""
+		for {
+			listCall := filter.Apply(service.Events.List(calendarId))
+			if (budget != nil) {
+				listCall = listCall.MaxResults(min(*budget - count, maxPageSize))
+			}
+			if (pageToken != "") {
+				listCall = listCall.PageToken(pageToken)
+			}
+
+			events, err := listCall.Context(ctx).Do()
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to list events of calendar '%s': %w", calendarId, err))
+				return
+			}
+
+			for _, event := range events.Items {
+				if (budget != nil && count >= *budget) {
+					return
+				}
+				// cancelled occurrences are listed but not shown, they must not spend the budget
+				if (filter.Hides(event)) {
+					continue
+				}
+				if !yield(event, nil) {
+					return
+				}
+				count++
+			}
+
+			pageToken = events.NextPageToken
+			if (pageToken == "" || (budget != nil && count >= *budget)) {
+				return
+			}
+		}
+	}
+}
+
+// SyncEvents fetches the events changed since syncToken, or all events of the calendar when syncToken is empty,
+// and returns them together with the token for the next incremental sync
+func (s *GAccount) SyncEvents(ctx context.Context, calendarId string, syncToken string, single bool) ([]*calendar.Event, string, error) {
+	service, err := s.service()
+	if (err != nil) {
+		return nil, "", err
+	}
+	listCall := service.Events.List(calendarId).ShowDeleted(true).SingleEvents(single).MaxResults(maxPageSize)
+	if (syncToken != "") {
+		listCall = listCall.SyncToken(syncToken)
 	}
 
+	events := make([]*calendar.Event, 0)
+	nextSyncToken := ""
+	err = listCall.Pages(ctx, func(page *calendar.Events) error {
+		events = append(events, page.Items...)
+		nextSyncToken = page.NextSyncToken
+		return nil
+	})
+
+	var apiErr *googleapi.Error
+	if (errors.As(err, &apiErr) && apiErr.Code == http.StatusGone) {
+		return nil, "", ErrSyncTokenExpired
+	}
+	if (err != nil) {
+		return nil, "", fmt.Errorf("failed to sync events of calendar '%s': %w", calendarId, err)
+	}
+
+	return events, nextSyncToken, nil
+}
+
+// Source describes the calendar from the metadata stored with the account, without asking Google
+func (s *GAccount) Source(calendarId string) figevent.Source {
+	source := figevent.Source{
+		Account: s.Name,
+		CalendarID: calendarId,
+	}
+	if cal := s.Calendars.Find(calendarId); cal != nil {
+		if (cal.Name() != cal.ID) {
+			source.CalendarSummary = cal.Name()
+		}
+		source.CalendarColor = cal.Color
+	}
+	return source
+}
+
+func (s *GAccount) InsertEvent(calendarId string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	created, err := s.Service.Events.Insert(calendarId, event).SendUpdates(sendUpdates).Do()
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to insert event into calendar '%s': %w", calendarId, err)
+	}
+	return created, nil
+}
+
+func (s *GAccount) GetEvent(calendarId string, eventId string) (*calendar.Event, error) {
+	event, err := s.Service.Events.Get(calendarId, eventId).Do()
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to get event '%s' from calendar '%s': %w", eventId, calendarId, err)
+	}
+	return event, nil
+}
+
+// PatchEvent updates only the fields which are set in event
+func (s *GAccount) PatchEvent(calendarId string, eventId string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	updated, err := s.Service.Events.Patch(calendarId, eventId, event).SendUpdates(sendUpdates).Do()
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to update event '%s' in calendar '%s': %w", eventId, calendarId, err)
+	}
+	return updated, nil
+}
+
+// ImportEvent adds a private copy of an existing event, keeping its iCalUID
+func (s *GAccount) ImportEvent(calendarId string, event *calendar.Event) (*calendar.Event, error) {
+	imported, err := s.Service.Events.Import(calendarId, event).Do()
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to import event '%s' into calendar '%s': %w", event.ICalUID, calendarId, err)
+	}
+	return imported, nil
+}
+
+// FindByICalUID returns the event and its modified instances sharing the iCalUID
+func (s *GAccount) FindByICalUID(calendarId string, iCalUID string) ([]*calendar.Event, error) {
+	events, err := s.Service.Events.List(calendarId).ICalUID(iCalUID).Do()
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to find event '%s' in calendar '%s': %w", iCalUID, calendarId, err)
+	}
 	return events.Items, nil
 }
 
+func (s *GAccount) DeleteEvent(calendarId string, eventId string, sendUpdates string) error {
+	err := s.Service.Events.Delete(calendarId, eventId).SendUpdates(sendUpdates).Do()
+	if (err != nil) {
+		return fmt.Errorf("failed to delete event '%s' from calendar '%s': %w", eventId, calendarId, err)
+	}
+	return nil
+}
+
+// FreeBusy returns busy intervals of the calendars, which may also be calendars of other people given by email
+func (s *GAccount) FreeBusy(ctx context.Context, calendarIds []string, from time.Time, to time.Time) ([]interval.Interval, error) {
+	service, err := s.service()
+	if (err != nil) {
+		return nil, err
+	}
+	busy := make([]interval.Interval, 0)
+	for chunk := range slices.Chunk(calendarIds, maxFreeBusyCalendars) {
+		items := make([]*calendar.FreeBusyRequestItem, len(chunk))
+		for i, calendarId := range chunk {
+			items[i] = &calendar.FreeBusyRequestItem{ Id: calendarId }
+		}
+
+		response, err := service.Freebusy.Query(&calendar.FreeBusyRequest{
+			TimeMin: from.Format(time.RFC3339),
+			TimeMax: to.Format(time.RFC3339),
+			Items: items,
+		}).Context(ctx).Do()
+		if (err != nil) {
+			return nil, fmt.Errorf("failed to query free/busy of account '%s': %w", s.Name, err)
+		}
+
+		for calendarId, calendarBusy := range response.Calendars {
+			if (len(calendarBusy.Errors) > 0) {
+				reasons := make([]string, len(calendarBusy.Errors))
+				for i, calendarErr := range calendarBusy.Errors {
+					reasons[i] = calendarErr.Reason
+				}
+				return nil, fmt.Errorf("failed to query free/busy of calendar '%s': %s", calendarId, strings.Join(reasons, ", "))
+			}
+			for _, period := range calendarBusy.Busy {
+				start, err := time.Parse(time.RFC3339, period.Start)
+				if (err != nil) {
+					return nil, fmt.Errorf("invalid busy period start '%s': %w", period.Start, err)
+				}
+				end, err := time.Parse(time.RFC3339, period.End)
+				if (err != nil) {
+					return nil, fmt.Errorf("invalid busy period end '%s': %w", period.End, err)
+				}
+				busy = append(busy, interval.New(start, end))
+			}
+		}
+	}
+
+	return busy, nil
+}
+
+// FindCalendars returns IDs of calendars in Calendars.All whose ID or summary matches the pattern,
+// which can be a literal, a glob or a /regular expression/
+func (s *GAccount) FindCalendars(expr string) ([]string, error) {
+	p, err := pattern.Compile(expr)
+	if (err != nil) {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for _, cal := range s.Calendars.All {
+		if (cal.Matches(p)) {
+			ids = append(ids, cal.ID)
+		}
+	}
+	if (len(ids) == 0) {
+		return nil, fmt.Errorf("no calendar of account '%s' matches '%s'", s.Name, expr)
+	}
+	return ids, nil
+}
+
+// ResolveCalendar turns a calendar name into its ID, names are compared by Calendar.IsNamed.
+// IDs, and names matching no calendar, such as 'primary' of configs without calendars, are returned unchanged
+func (s *GAccount) ResolveCalendar(name string) (string, error) {
+	if (s.Calendars.Find(name) != nil) {
+		return name, nil
+	}
+
+	ids := make([]string, 0)
+	for _, cal := range s.Calendars.All {
+		if (cal.IsNamed(name)) {
+			ids = append(ids, cal.ID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return name, nil
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("calendar name '%s' of account '%s' is ambiguous, use one of IDs %v", name, s.Name, ids)
+}
+
+// Select limits the calendars returned by ResolveCalendars to the ones accepted by keep,
+// without changing whitelist and blacklist. Calendars unknown to Calendars.All are passed by ID only
+func (s *GAccount) Select(keep func(cal Calendar) bool) {
+	s.selected = keep
+}
+
 func (s *GAccount) ResolveCalendars() ([]string) {
+	ids := s.configuredCalendars()
+	if (s.selected == nil) {
+		return ids
+	}
+
+	selected := make([]string, 0, len(ids))
+	for _, id := range ids {
+		cal := Calendar{ ID: id }
+		if found := s.Calendars.Find(id); (found != nil) {
+			cal = *found
+		}
+		if (s.selected(cal)) {
+			selected = append(selected, id)
+		}
+	}
+	return selected
+}
+
+func (s *GAccount) configuredCalendars() ([]string) {
 	if (len(s.Calendars.WhiteList) > 0) {
 		return s.Calendars.WhiteList
 	}
 
-	return set.NewSet(s.Calendars.All...).Difference(set.NewSet(s.Calendars.BlackList...)).ToSlice();
+	// calendars hidden in Google Calendar are shown only when whitelisted
+	visible := make([]string, 0, len(s.Calendars.All))
+	for _, cal := range s.Calendars.All {
+		if (!cal.Hidden) {
+			visible = append(visible, cal.ID)
+		}
+	}
+	return set.NewSet(visible...).Difference(set.NewSet(s.Calendars.BlackList...)).ToSlice();
+}
+
+// seedStore keeps the seed of an account in the keyring, guarded by a lock file shared between processes
+type seedStore struct {
+	keyring		*typedkeyring.Keyring[gaseed.GASeed]
+	name		string
+	lockPath	string
+}
+
+func newSeedStore(serviceName string, accountName string) *seedStore {
+	dir, err := os.UserCacheDir()
+	if (err != nil) {
+		dir = os.TempDir()
+	}
+	return &seedStore{
+		keyring: typedkeyring.New[gaseed.GASeed](serviceName),
+		name: accountName,
+		lockPath: filepath.Join(dir, serviceName, "locks", url.PathEscape(accountName) + ".lock"),
+	}
+}
+
+func (s *seedStore) Lock() (func(), error) {
+	return filelock.Lock(s.lockPath, lockTimeout)
+}
+
+func (s *seedStore) Load() (*gaseed.GASeed, error) {
+	return s.keyring.Load(s.name)
+}
+
+func (s *seedStore) Save(seed *gaseed.GASeed) error {
+	return s.keyring.Save(s.name, seed)
+}
+
+// InspectToken checks with Google that the stored token of the account is still usable
+func InspectToken(ctx context.Context, serviceName string, accountName string) (*gaseed.TokenInfo, error) {
+	store := newSeedStore(serviceName, accountName)
+	gaSeed, err := store.Load()
+	if (err != nil) {
+		return nil, err
+	}
+	return gaSeed.Inspect(ctx, accountName, store)
+}
+
+// HasScope checks that the user granted the scope to the stored token of the account
+func HasScope(ctx context.Context, serviceName string, accountName string, scope string) (bool, error) {
+	store := newSeedStore(serviceName, accountName)
+	gaSeed, err := store.Load()
+	if (err != nil) {
+		return false, err
+	}
+	return gaSeed.HasScope(ctx, accountName, store, scope)
 }
 
 func getService(serviceName string, accountName string) (*calendar.Service, error) {
-	kr := typedkeyring.New[gaseed.GASeed](serviceName)
-	gaSeed, err := kr.Load(accountName)
+	store := newSeedStore(serviceName, accountName)
+	gaSeed, err := store.Load()
 	if err != nil {
 		return nil, err
 	}
 
-	client := gaSeed.GetClient()
+	client := gaSeed.GetClient(accountName, store)
 	return calendar.NewService(context.Background(), option.WithHTTPClient(client))
 }
 
-func getCalendars(service *calendar.Service) ([]string, error) {
-	calendars, err := service.CalendarList.List().Do()
+func getCalendars(ctx context.Context, service *calendar.Service) ([]Calendar, error) {
+	calendars := make([]Calendar, 0)
+	err := service.CalendarList.List().ShowHidden(true).Pages(ctx, func(list *calendar.CalendarList) error {
+		for _, entry := range list.Items {
+			calendars = append(calendars, newCalendar(entry))
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	calendarNames := make([]string, len(calendars.Items))
-	for i, cal := range calendars.Items {
-		calendarNames[i] = cal.Id
-	}
-
-	return calendarNames, nil
+	return calendars, nil
 }
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package gaccount
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/EugeneShtoka/figoro/lib/pattern"
+)
+
+// TestCalendarNames checks that patterns and calendar flags agree on which calendar a name stands for
+func TestCalendarNames(t *testing.T) {
+	account := &GAccount{
+		Name: "work",
+		Calendars: GCalendars{
+			All: []Calendar{
+				{ ID: "alice@example.com", Summary: "alice@example.com", Primary: true },
+				{ ID: "team@group.calendar.google.com", Summary: "Team" },
+				{ ID: "shared@group.calendar.google.com", Summary: "Holidays", SummaryOverride: "Days off" },
+			},
+		},
+	}
+	tests := []struct {
+		name	string
+		want	string
+	}{
+		{ "primary", "alice@example.com" },
+		{ "Primary", "alice@example.com" },
+		{ "alice@example.com", "alice@example.com" },
+		{ "team", "team@group.calendar.google.com" },
+		{ "TEAM", "team@group.calendar.google.com" },
+		{ "Holidays", "shared@group.calendar.google.com" },
+		{ "days off", "shared@group.calendar.google.com" },
+	}
+	for _, test := range tests {
+		resolved, err := account.ResolveCalendar(test.name)
+		if (err != nil || resolved != test.want) {
+			t.Errorf("ResolveCalendar(%q) = %q, %v, want %q", test.name, resolved, err, test.want)
+		}
+
+		found, err := account.FindCalendars(test.name)
+		if (err != nil || !slices.Equal(found, []string{ test.want })) {
+			t.Errorf("FindCalendars(%q) = %v, %v, want [%s]", test.name, found, err, test.want)
+		}
+
+		p, err := pattern.Compile(test.name)
+		if (err != nil) {
+			t.Fatalf("Compile(%q) failed: %v", test.name, err)
+		}
+		account.Calendars.WhiteList = []string{ "alice@example.com", "team@group.calendar.google.com", "shared@group.calendar.google.com" }
+		account.Select(func(cal Calendar) bool { return cal.Matches(p) })
+		selected := account.ResolveCalendars()
+		if (!slices.Equal(selected, []string{ test.want })) {
+			t.Errorf("selection of %q = %v, want [%s]", test.name, selected, test.want)
+		}
+	}
+
+	found, err := account.FindCalendars("T*")
+	if (err != nil || !slices.Equal(found, []string{ "team@group.calendar.google.com" })) {
+		t.Errorf("FindCalendars(T*) = %v, %v, want the Team calendar", found, err)
+	}
+}
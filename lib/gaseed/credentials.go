@@ -0,0 +1,104 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package gaseed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+)
+
+// Credentials is the OAuth client from the client_secret_*.json file downloaded from Google Cloud Console
+type Credentials struct {
+	// Type is installed for desktop clients and web for web application clients
+	Type			string
+	ClientID		string		`json:"client_id"`
+	ClientSecret	string		`json:"client_secret"`
+	RedirectURIs	[]string	`json:"redirect_uris"`
+}
+
+// LoadCredentials reads a Google client_secret_*.json file
+func LoadCredentials(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	credentials, err := ParseCredentials(data)
+	if (err != nil) {
+		return nil, fmt.Errorf("invalid credentials file '%s': %w", path, err)
+	}
+	return credentials, nil
+}
+
+// ParseCredentials parses both the installed and the web application variants of client_secret_*.json
+func ParseCredentials(data []byte) (*Credentials, error) {
+	var file struct {
+		Installed	*Credentials	`json:"installed"`
+		Web			*Credentials	`json:"web"`
+	}
+	err := json.Unmarshal(data, &file)
+	if (err != nil) {
+		return nil, err
+	}
+
+	var credentials *Credentials
+	switch {
+	case file.Installed != nil:
+		credentials = file.Installed
+		credentials.Type = "installed"
+	case file.Web != nil:
+		credentials = file.Web
+		credentials.Type = "web"
+	default:
+		return nil, fmt.Errorf("expected an 'installed' or 'web' client")
+	}
+
+	if (credentials.ClientID == "" || credentials.ClientSecret == "") {
+		return nil, fmt.Errorf("client_id and client_secret are required")
+	}
+	return credentials, nil
+}
+
+// ValidateRedirectURL checks that Google accepts redirects of the client to redirectURL.
+// Web clients need the exact URL registered, installed clients accept any port of a registered loopback URL
+func (c *Credentials) ValidateRedirectURL(redirectURL string) error {
+	if (slices.Contains(c.RedirectURIs, redirectURL)) {
+		return nil
+	}
+
+	if (c.Type == "installed") {
+		redirect, err := url.Parse(redirectURL)
+		if (err != nil) {
+			return err
+		}
+		for _, uri := range c.RedirectURIs {
+			registered, err := url.Parse(uri)
+			if (err == nil && registered.Scheme == redirect.Scheme && registered.Port() == "" && isLoopback(registered.Hostname())) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("redirect URL '%s' is not registered for the %s client, registered: %v", redirectURL, c.Type, c.RedirectURIs)
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
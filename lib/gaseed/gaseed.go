@@ -18,18 +18,65 @@ package gaseed
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/rs/zerolog"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
 )
 
+// Scopes requested for new accounts: reading calendar lists and managing events
+var Scopes = []string{calendar.CalendarReadonlyScope, calendar.CalendarEventsScope}
+
+// ErrInvalidGrant is returned when Google no longer accepts the refresh token because it was revoked or expired
+var ErrInvalidGrant = errors.New("refresh token was revoked or expired")
+
+// tokenInfoURL is the Google endpoint describing access tokens
+var tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// revokeURL is the Google endpoint revoking grants
+var revokeURL = "https://oauth2.googleapis.com/revoke"
+
+// logger reports problems which do not fail requests, like refreshed tokens which could not be saved
+var logger = zerolog.Nop()
+
+// SetLogger selects the logger of token sources created afterwards
+func SetLogger(l zerolog.Logger) {
+	logger = l
+}
+
+// TokenInfo describes the access token of an account as Google sees it
+type TokenInfo struct {
+	Expiry	time.Time
+	Scopes	[]string
+}
+
+// Store keeps seeds of an account between runs, it is shared by concurrent figoro processes
+type Store interface {
+	// Lock keeps other processes from refreshing the token until the returned function is called
+	Lock() (func(), error)
+	Load() (*GASeed, error)
+	Save(seed *GASeed) error
+}
 
 type GASeed struct {
 	Token 			*oauth2.Token
 	Config 			*oauth2.Config
+	// GrantedScopes are the scopes the user agreed to, which may be fewer than Config.Scopes with granular consent.
+	// They are empty for seeds stored before they were recorded
+	GrantedScopes	[]string		`json:",omitempty"`
+	// verifier is the PKCE code verifier of the authorization in progress, it is never stored
+	verifier		string
 }
 
 func New(clientID string, clientSecret string, bindAddress string, authEndpoint string) *GASeed {
@@ -38,17 +85,193 @@ func New(clientID string, clientSecret string, bindAddress string, authEndpoint
 		ClientSecret: clientSecret,
 		RedirectURL: fmt.Sprintf("http://%s%s", bindAddress, authEndpoint),
 		Endpoint:     google.Endpoint,
-		Scopes:       []string{calendar.CalendarReadonlyScope},
+		Scopes:       Scopes,
 	}
 	return &GASeed{	Config: config }
 }
 
-func (s *GASeed) SetToken(code string) (*GASeed, error) {
+// AuthCodeURL returns the URL of the consent page, protecting the code with a fresh PKCE (S256) challenge
+func (s *GASeed) AuthCodeURL(state string) string {
+	s.verifier = oauth2.GenerateVerifier()
+	return s.Config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(s.verifier))
+}
+
+func (s *GASeed) SetToken(ctx context.Context, code string) (*GASeed, error) {
+	var options []oauth2.AuthCodeOption
+	if (s.verifier != "") {
+		options = append(options, oauth2.VerifierOption(s.verifier))
+	}
+
 	var err error
-	s.Token, err = s.Config.Exchange(context.Background(), code)
+	s.Token, err = s.Config.Exchange(ctx, code, options...)
+	if (err == nil) {
+		s.GrantedScopes = grantedScopes(s.Token)
+	}
 	return s, err
 }
 
-func (s *GASeed) GetClient() *http.Client {
-	return s.Config.Client(context.Background(), s.Token)
-}
\ No newline at end of file
+// grantedScopes reads the scopes Google returns next to tokens, nil when they are missing
+func grantedScopes(token *oauth2.Token) []string {
+	scope, ok := token.Extra("scope").(string)
+	if (!ok || scope == "") {
+		return nil
+	}
+	return strings.Fields(scope)
+}
+
+// GetClient returns a client which refreshes the token when it expires and saves refreshed tokens to the store
+func (s *GASeed) GetClient(name string, store Store) *http.Client {
+	return oauth2.NewClient(context.Background(), s.TokenSource(name, store))
+}
+
+// TokenSource returns valid tokens of the account, refreshing and saving them to the store when they expire
+func (s *GASeed) TokenSource(name string, store Store) oauth2.TokenSource {
+	return &persistingTokenSource{ name: name, seed: s, store: store }
+}
+
+// Inspect refreshes the token if needed and asks Google about it, which also fails when the grant was revoked
+func (s *GASeed) Inspect(ctx context.Context, name string, store Store) (*TokenInfo, error) {
+	token, err := s.TokenSource(name, store).Token()
+	if (err != nil) {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenInfoURL + "?access_token=" + url.QueryEscape(token.AccessToken), nil)
+	if (err != nil) {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to inspect token of account '%s': %w", name, err)
+	}
+	defer response.Body.Close()
+
+	var info struct {
+		Scope		string	`json:"scope"`
+		Exp			string	`json:"exp"`
+		Error		string	`json:"error"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&info)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to read token info of account '%s': %w", name, err)
+	}
+	if (response.StatusCode != http.StatusOK) {
+		if (info.Error == "invalid_token") {
+			return nil, fmt.Errorf("%w, re-authorize account '%s'", ErrInvalidGrant, name)
+		}
+		return nil, fmt.Errorf("failed to inspect token of account '%s': %s %s", name, response.Status, info.Error)
+	}
+
+	exp, err := strconv.ParseInt(info.Exp, 10, 64)
+	if (err != nil) {
+		return nil, fmt.Errorf("invalid token expiry '%s': %w", info.Exp, err)
+	}
+	return &TokenInfo{ Expiry: time.Unix(exp, 0), Scopes: strings.Fields(info.Scope) }, nil
+}
+
+// persistingTokenSource refreshes tokens under the lock of the store, so that a token refreshed
+// by one process, together with a rotated refresh token, is reused by others instead of being overwritten
+type persistingTokenSource struct {
+	name	string
+	seed	*GASeed
+	store	Store
+	mu		sync.Mutex
+}
+
+func (ts *persistingTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if (ts.seed.Token.Valid()) {
+		return ts.seed.Token, nil
+	}
+
+	unlock, err := ts.store.Lock()
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to lock token of account '%s': %w", ts.name, err)
+	}
+	defer unlock()
+
+	stored, err := ts.store.Load()
+	if (err == nil && stored.Token != nil) {
+		ts.seed.Token = stored.Token
+		if (stored.GrantedScopes != nil) {
+			ts.seed.GrantedScopes = stored.GrantedScopes
+		}
+		if (ts.seed.Token.Valid()) {
+			return ts.seed.Token, nil
+		}
+	}
+
+	token, err := ts.seed.Config.TokenSource(context.Background(), ts.seed.Token).Token()
+	if (err != nil) {
+		var retrieveErr *oauth2.RetrieveError
+		if (errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant") {
+			return nil, fmt.Errorf("%w, re-authorize account '%s'", ErrInvalidGrant, ts.name)
+		}
+		return nil, fmt.Errorf("failed to refresh token of account '%s': %w", ts.name, err)
+	}
+
+	ts.seed.Token = token
+	if scopes := grantedScopes(token); (scopes != nil) {
+		ts.seed.GrantedScopes = scopes
+	}
+	// the token is usable even if it was not saved, but a rotated refresh token is lost with it
+	err = ts.store.Save(ts.seed)
+	if (err != nil) {
+		logger.Warn().Err(err).Str("account", ts.name).Msg("failed to save refreshed token, the account may have to be re-authorized")
+	}
+	return token, nil
+}
+
+// Revoke withdraws the access granted to the client, Google invalidates both the refresh and the access tokens.
+// A token which is already invalid is not an error
+func (s *GASeed) Revoke(ctx context.Context) error {
+	if (s.Token == nil) {
+		return nil
+	}
+	token := s.Token.RefreshToken
+	if (token == "") {
+		token = s.Token.AccessToken
+	}
+
+	form := url.Values{ "token": { token } }
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
+	if (err != nil) {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := http.DefaultClient.Do(request)
+	if (err != nil) {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	defer response.Body.Close()
+
+	if (response.StatusCode == http.StatusOK) {
+		return nil
+	}
+	var revokeErr struct {
+		Error				string	`json:"error"`
+		ErrorDescription	string	`json:"error_description"`
+	}
+	json.NewDecoder(response.Body).Decode(&revokeErr)
+	if (revokeErr.Error == "invalid_token") {
+		return nil
+	}
+	return fmt.Errorf("failed to revoke token: %s %s %s", response.Status, revokeErr.Error, revokeErr.ErrorDescription)
+}
+
+// HasScope reports whether the user granted the scope, which is asked from Google
+// when the granted scopes were not recorded at authorization
+func (s *GASeed) HasScope(ctx context.Context, name string, store Store, scope string) (bool, error) {
+	granted := s.GrantedScopes
+	if (granted == nil) {
+		info, err := s.Inspect(ctx, name, store)
+		if (err != nil) {
+			return false, err
+		}
+		granted = info.Scopes
+	}
+	return slices.Contains(granted, scope), nil
+}
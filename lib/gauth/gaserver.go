@@ -17,17 +17,23 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package gauth
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
-	"text/template"
+	"net/url"
+	"os"
+	"html/template"
+	"strings"
+	"time"
 
 	"github.com/EugeneShtoka/figoro/lib/gaseed"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/skratchdot/open-golang/open"
-	"golang.org/x/oauth2"
 )
 
 var (
@@ -35,15 +41,60 @@ var (
 	authEndpoint = "/codeResponse"
 )
 
+const (
+	// ModeBrowser opens the consent page in the local browser, which redirects back to the loopback server
+	ModeBrowser = "browser"
+	// ModeHeadless prints the consent page URL and reads the redirect URL or the code pasted by the user.
+	// The device authorization grant is not offered, Google allows no Calendar scopes in it
+	ModeHeadless = "headless"
+)
+
+// Modes lists the supported ways of authorization
+var Modes = []string{ModeBrowser, ModeHeadless}
+
+// ErrAccessDenied is returned when the user declines the consent screen
+var ErrAccessDenied = errors.New("access was denied on the consent screen")
+
+// loopbackHosts are tried in order, IPv6 is used on machines without IPv4 loopback
+var loopbackHosts = []string{host, "::1"}
+
+var (
+	successPage = template.Must(template.New("success").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>figoro: access granted</title></head>
+<body style="font-family: sans-serif; margin: 4em auto; max-width: 40em">
+<h1>Access granted</h1>
+<p>figoro received the authorization. You can close this tab and return to the terminal.</p>
+</body>
+</html>
+`))
+	failurePage = template.Must(template.New("failure").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>figoro: authorization failed</title></head>
+<body style="font-family: sans-serif; margin: 4em auto; max-width: 40em">
+<h1>{{.Name}}</h1>
+<p>{{.Description}}</p>
+<p>Return to the terminal for details.</p>
+</body>
+</html>
+`))
+)
+
 type GAServer struct {
 	BindAddress		string
 	AuthEndpoint	string
 	State       	string
 	Code			chan string
+	failed			chan error
 	Listener    	net.Listener
 	Server      	*http.Server
 	GASeed			*gaseed.GASeed
 	Logger			*zerolog.Logger
+	Mode			string
+	// Timeout limits the wait for the user to grant access, zero waits until the context is done
+	Timeout			time.Duration
+	In				io.Reader
+	Out				io.Writer
 }
 
 type GAError struct {
@@ -51,9 +102,14 @@ type GAError struct {
 	Description string
 }
 
+// RedirectURL is the URL Google redirects to after authorization when the server listens on the port
+func RedirectURL(port string) string {
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(host, port), authEndpoint)
+}
+
 // New makes the webserver for collecting auth
 func New(clientID string, clientSecret string, port string, logger *zerolog.Logger) *GAServer {
-	bindAddress := fmt.Sprintf("%s:%s", host, port)
+	bindAddress := net.JoinHostPort(host, port)
 	return &GAServer{
 		BindAddress: bindAddress,
 		AuthEndpoint: authEndpoint,
@@ -61,24 +117,113 @@ func New(clientID string, clientSecret string, port string, logger *zerolog.Logg
 		Logger:		logger,
 		GASeed:		gaseed.New(clientID, clientSecret, bindAddress, authEndpoint),
 		Code:		make(chan string, 1),
+		failed:		make(chan error, 1),
+		Mode:		ModeBrowser,
+		In:			os.Stdin,
+		Out:		os.Stdout,
 	}
 }
 
-	// Reply with the response to the user and to the channel
+// deliver passes the code to Authorize, codes arriving after the first one are dropped
+func (s *GAServer) deliver(code string) {
+	select {
+	case s.Code <- code:
+	default:
+	}
+}
+
+// fail stops Authorize with the error, errors arriving after the first one are dropped
+func (s *GAServer) fail(err error) {
+	select {
+	case s.failed <- err:
+	default:
+	}
+}
+
+// authError turns the error parameter of the redirect into an error
+func authError(name string, description string) error {
+	if (name == "access_denied") {
+		return ErrAccessDenied
+	}
+	if (description != "") {
+		return fmt.Errorf("authorization failed: %s: %s", name, description)
+	}
+	return fmt.Errorf("authorization failed: %s", name)
+}
+
+// parseCode accepts either the URL the browser was redirected to or the bare code
+func (s *GAServer) parseCode(input string) (string, error) {
+	if (!strings.Contains(input, "://")) {
+		return input, nil
+	}
+
+	redirect, err := url.Parse(input)
+	if (err != nil) {
+		return "", fmt.Errorf("invalid redirect URL: %w", err)
+	}
+	query := redirect.Query()
+	// Google echoes the state on errors too, redirects of other requests must not abort this one
+	if (query.Get("state") != s.State) {
+		return "", fmt.Errorf("auth state doesn't match, expecting %q got %q", s.State, query.Get("state"))
+	}
+	if (query.Get("error") != "") {
+		return "", authError(query.Get("error"), query.Get("error_description"))
+	}
+	if (query.Get("code") == "") {
+		return "", errors.New("no code in redirect URL")
+	}
+	return query.Get("code"), nil
+}
+
+// readCode reads lines from In until one of them holds a code or the context is done.
+// Files which support deadlines are unblocked when the context is done, otherwise
+// the pending read ends with the next line, which is dropped
+func (s *GAServer) readCode(ctx context.Context) {
+	if file, ok := s.In.(*os.File); (ok) {
+		stop := context.AfterFunc(ctx, func() { file.SetReadDeadline(time.Now()) })
+		defer stop()
+		defer file.SetReadDeadline(time.Time{})
+	}
+
+	scanner := bufio.NewScanner(s.In)
+	for scanner.Scan() {
+		if (ctx.Err() != nil) {
+			return
+		}
+		input := strings.TrimSpace(scanner.Text())
+		if (input == "") {
+			continue
+		}
+		code, err := s.parseCode(input)
+		if (errors.Is(err, ErrAccessDenied)) {
+			s.fail(err)
+			return
+		}
+		if (err != nil) {
+			fmt.Fprintf(s.Out, "%v, try again:\n", err)
+			continue
+		}
+		s.deliver(code)
+		return
+	}
+}
+
+// Reply with the response to the user and to the channel
 func (s *GAServer) reply(w http.ResponseWriter, res *GAError) {
 	var (
 		status int
-		responseTemplate string
+		page *template.Template
 	)
 	if (res == nil) {
 		status = http.StatusOK
+		page = successPage
 	} else {
 		status = http.StatusBadRequest
+		page = failurePage
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(status)
-	w.Header().Set("Content-Type", "text/html")
-	var t = template.Must(template.New("authResponse").Parse(responseTemplate))
-	var err = t.Execute(w, res)
+	var err = page.Execute(w, res)
 	if  err != nil {
 		s.Logger.Error().Msg(fmt.Sprintf("Could not execute template for web response: %s", err))
 	}
@@ -96,29 +241,45 @@ func (s *GAServer) HandleAuth(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// get code, error if empty
-	var code = req.Form.Get("code")
-	if code == "" {
+	// check state first, Google echoes it on error redirects too, so that requests
+	// which did not come from this authorization cannot abort it
+	var state = req.Form.Get("state")
+	if state != s.State {
 		s.reply(w, &GAError{
-			Name:        "Auth Error",
-			Description: "No code returned by remote server",
+			Name:        "Auth state doesn't match",
+			Description: fmt.Sprintf("Expecting %q got %q", s.State, state),
 		})
 		return
 	}
 
-	// check state
-	var state = req.Form.Get("state")
-	if state != s.State {
+	// Google redirects with error instead of code when the user declines or the request is invalid
+	if authErrorName := req.Form.Get("error"); authErrorName != "" {
+		err := authError(authErrorName, req.Form.Get("error_description"))
+		name := "Authorization failed"
+		if (errors.Is(err, ErrAccessDenied)) {
+			name = "Access denied"
+		}
 		s.reply(w, &GAError{
-			Name:        "Auth state doesn't match",
-			Description: fmt.Sprintf("Expecting %q got %q", s.State, state),
+			Name:        name,
+			Description: err.Error(),
+		})
+		s.fail(err)
+		return
+	}
+
+	// get code, error if empty
+	var code = req.Form.Get("code")
+	if code == "" {
+		s.reply(w, &GAError{
+			Name:        "Auth Error",
+			Description: "No code returned by remote server",
 		})
 		return
 	}
 
 	// code OK
 	s.reply(w, nil)
-	s.Code <- req.FormValue("code")
+	s.deliver(req.FormValue("code"))
 }
 
 // Init gets the internal web server ready to receive config details
@@ -134,13 +295,45 @@ func (gaServer *GAServer) Init() error {
 	mux.HandleFunc(gaServer.AuthEndpoint, gaServer.HandleAuth)
 
 	var err error
-	gaServer.Listener, err = net.Listen("tcp", gaServer.BindAddress)
+	gaServer.Listener, err = gaServer.listen()
 	if err != nil {
 		return fmt.Errorf("failed to start listener: %w", err)
 	}
+
+	// the redirect has to point to the address actually bound
+	address := gaServer.Listener.Addr().String()
+	if (address != gaServer.BindAddress) {
+		gaServer.Logger.Warn().Str("configured", gaServer.BindAddress).Str("bound", address).
+			Msg("configured address is not available, web application clients need the new redirect URL registered")
+		fmt.Fprintf(gaServer.Out, "%s is not available, listening on %s instead\n", gaServer.BindAddress, address)
+	}
+	gaServer.BindAddress = address
+	gaServer.Server.Addr = address
+	gaServer.GASeed.Config.RedirectURL = fmt.Sprintf("http://%s%s", address, gaServer.AuthEndpoint)
 	return nil
 }
 
+// listen binds the configured port, falling back to an ephemeral one when it is busy
+// and to the IPv6 loopback when the IPv4 one is not available
+func (gaServer *GAServer) listen() (net.Listener, error) {
+	_, port, err := net.SplitHostPort(gaServer.BindAddress)
+	if (err != nil) {
+		return nil, err
+	}
+
+	var errs []error
+	for _, loopback := range loopbackHosts {
+		for _, candidate := range []string{port, "0"} {
+			listener, err := net.Listen("tcp", net.JoinHostPort(loopback, candidate))
+			if (err == nil) {
+				return listener, nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
 // Serve the auth server, doesn't return
 func (s *GAServer) Serve() error {
 	var err = s.Server.Serve(s.Listener)
@@ -154,7 +347,14 @@ func (s *GAServer) Stop() {
 	s.Server.Close()
 }
 
+// Authorize asks the user to grant access in the way selected by Mode and exchanges the code for a token
 func (gaServer *GAServer) Authorize(ctx context.Context) (*gaseed.GASeed, error) {
+	if (gaServer.Timeout > 0) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gaServer.Timeout)
+		defer cancel()
+	}
+
 	err := gaServer.Init()
 	if err != nil {
 		return nil, fmt.Errorf("failed to start auth webserver: %w", err)
@@ -163,17 +363,38 @@ func (gaServer *GAServer) Authorize(ctx context.Context) (*gaseed.GASeed, error)
 	go gaServer.Serve()
 	defer gaServer.Stop()
 
-	// Open the URL for the user to visit
-	var authUrl = gaServer.GASeed.Config.AuthCodeURL(gaServer.State, oauth2.AccessTypeOffline)
-	open.Start(authUrl)
+	// stops reading pasted codes once the redirect arrives or the wait is over
+	ctx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
 
-	fmt.Printf("Waiting for code\n")
-	var code = <- gaServer.Code
+	var authUrl = gaServer.GASeed.AuthCodeURL(gaServer.State)
+	if (gaServer.Mode == ModeHeadless) {
+		fmt.Fprintf(gaServer.Out, "Open this URL in a browser on any machine:\n\n%s\n\n", authUrl)
+		fmt.Fprintf(gaServer.Out, "After granting access the browser is redirected to %s, which may fail to load.\n", gaServer.BindAddress)
+		fmt.Fprintf(gaServer.Out, "Paste the URL from the address bar, or just the code parameter:\n")
+		go gaServer.readCode(ctx)
+	} else {
+		// Open the URL for the user to visit
+		err = open.Start(authUrl)
+		if (err != nil) {
+			gaServer.Logger.Warn().Err(err).Msg("failed to open browser")
+		}
+		fmt.Fprintf(gaServer.Out, "If the browser did not open, visit:\n\n%s\n\n", authUrl)
+		fmt.Fprintf(gaServer.Out, "Waiting for code\n")
+	}
+
+	var code string
+	select {
+	case code = <-gaServer.Code:
+	case err = <-gaServer.failed:
+		return nil, err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("stopped waiting for authorization: %w", ctx.Err())
+	}
 
 	if	code == "" {
-		return nil, fmt.Errorf("failed to start auth webserver: %w", err)
+		return nil, errors.New("no code returned by remote server")
 	} 
 
-	return gaServer.GASeed.SetToken(code)
+	return gaServer.GASeed.SetToken(ctx, code)
 }
-
@@ -0,0 +1,342 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+var (
+	// durationPattern matches RFC 5545 durations like P1W, P1DT2H, -PT15M
+	durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+	recurrenceProperties = []string{"RRULE", "EXRULE", "RDATE", "EXDATE"}
+)
+
+// contentLine is one unfolded line of an iCalendar file, e.g. DTSTART;TZID=Europe/Berlin:20240506T100000
+type contentLine struct {
+	Name	string
+	Params	map[string]string
+	Value	string
+	Raw		string
+}
+
+// Decode parses all VEVENT components into Calendar API events, other components are skipped
+func Decode(r io.Reader) ([]*calendar.Event, error) {
+	lines, err := unfold(r)
+	if (err != nil) {
+		return nil, err
+	}
+
+	events := make([]*calendar.Event, 0)
+	var event *calendar.Event
+	var duration *time.Duration
+	components := make([]string, 0)
+	for number, raw := range lines {
+		line, err := parseLine(raw)
+		if (err != nil) {
+			return nil, fmt.Errorf("line %d: %w", number + 1, err)
+		}
+
+		switch line.Name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(line.Value))
+			if (strings.EqualFold(line.Value, "VEVENT")) {
+				event = &calendar.Event{}
+				duration = nil
+			}
+			continue
+		case "END":
+			if (len(components) == 0 || components[len(components) - 1] != strings.ToUpper(line.Value)) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", number + 1, line.Value)
+			}
+			components = components[:len(components) - 1]
+			if (strings.EqualFold(line.Value, "VEVENT")) {
+				err = finishEvent(event, duration)
+				if (err != nil) {
+					return nil, fmt.Errorf("line %d: %w", number + 1, err)
+				}
+				events = append(events, event)
+				event = nil
+			}
+			continue
+		}
+
+		if (event == nil || len(components) == 0) {
+			continue
+		}
+		if (components[len(components) - 1] == "VALARM") {
+			err = decodeAlarm(event, line)
+		} else if (components[len(components) - 1] == "VEVENT") {
+			duration, err = decodeProperty(event, line, duration)
+		}
+		if (err != nil) {
+			return nil, fmt.Errorf("line %d: %w", number + 1, err)
+		}
+	}
+
+	if (len(components) > 0) {
+		return nil, fmt.Errorf("component %s is not closed", components[len(components) - 1])
+	}
+	return events, nil
+}
+
+// unfold joins continuation lines, which start with a space or a tab, to the previous line
+func unfold(r io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t"))) {
+			lines[len(lines) - 1] += line[1:]
+			continue
+		}
+		if (line != "") {
+			lines = append(lines, line)
+		}
+	}
+	if (scanner.Err() != nil) {
+		return nil, fmt.Errorf("failed to read calendar: %w", scanner.Err())
+	}
+	return lines, nil
+}
+
+// parseLine splits a content line into name, parameters and value, respecting quoted parameter values
+func parseLine(raw string) (*contentLine, error) {
+	line := &contentLine{ Params: make(map[string]string), Raw: raw }
+
+	quoted := false
+	valueStart := -1
+	segments := make([]string, 0)
+	segmentStart := 0
+	for i, char := range raw {
+		if (char == '"') {
+			quoted = !quoted
+		} else if (!quoted && char == ';') {
+			segments = append(segments, raw[segmentStart:i])
+			segmentStart = i + 1
+		} else if (!quoted && char == ':') {
+			segments = append(segments, raw[segmentStart:i])
+			valueStart = i + 1
+			break
+		}
+	}
+	if (valueStart < 0) {
+		return nil, fmt.Errorf("invalid content line '%s'", raw)
+	}
+
+	line.Name = strings.ToUpper(segments[0])
+	for _, segment := range segments[1:] {
+		name, value, _ := strings.Cut(segment, "=")
+		line.Params[strings.ToUpper(name)] = strings.Trim(value, "\"")
+	}
+	line.Value = raw[valueStart:]
+	return line, nil
+}
+
+func decodeProperty(event *calendar.Event, line *contentLine, duration *time.Duration) (*time.Duration, error) {
+	var err error
+	switch line.Name {
+	case "UID":
+		event.ICalUID = line.Value
+	case "SUMMARY":
+		event.Summary = unescapeText(line.Value)
+	case "DESCRIPTION":
+		event.Description = unescapeText(line.Value)
+	case "LOCATION":
+		event.Location = unescapeText(line.Value)
+	case "DTSTART":
+		event.Start, err = decodeDateTime(line)
+	case "DTEND":
+		event.End, err = decodeDateTime(line)
+	case "RECURRENCE-ID":
+		event.OriginalStartTime, err = decodeDateTime(line)
+	case "DURATION":
+		var value time.Duration
+		value, err = parseDuration(line.Value)
+		duration = &value
+	case "STATUS":
+		if status, ok := lookupValue(eventStatuses, strings.ToUpper(line.Value)); ok {
+			event.Status = status
+		}
+	case "TRANSP":
+		if (strings.EqualFold(line.Value, "TRANSPARENT")) {
+			event.Transparency = "transparent"
+		}
+	case "CLASS":
+		if (strings.EqualFold(line.Value, "PRIVATE") || strings.EqualFold(line.Value, "CONFIDENTIAL")) {
+			event.Visibility = strings.ToLower(line.Value)
+		}
+	case "SEQUENCE":
+		event.Sequence, err = strconv.ParseInt(line.Value, 10, 64)
+	case "ORGANIZER":
+		event.Organizer = &calendar.EventOrganizer{ Email: mailAddress(line.Value), DisplayName: line.Params["CN"] }
+	case "ATTENDEE":
+		event.Attendees = append(event.Attendees, decodeAttendee(line))
+	default:
+		for _, name := range recurrenceProperties {
+			if (line.Name == name) {
+				event.Recurrence = append(event.Recurrence, line.Raw)
+			}
+		}
+	}
+	if (err != nil) {
+		return nil, fmt.Errorf("invalid %s: %w", line.Name, err)
+	}
+	return duration, nil
+}
+
+func decodeAttendee(line *contentLine) *calendar.EventAttendee {
+	attendee := &calendar.EventAttendee{
+		Email: mailAddress(line.Value),
+		DisplayName: line.Params["CN"],
+		Optional: strings.EqualFold(line.Params["ROLE"], "OPT-PARTICIPANT"),
+		Resource: strings.EqualFold(line.Params["CUTYPE"], "RESOURCE") || strings.EqualFold(line.Params["CUTYPE"], "ROOM"),
+	}
+	if status, ok := lookupValue(partStats, strings.ToUpper(line.Params["PARTSTAT"])); ok {
+		attendee.ResponseStatus = status
+	}
+	return attendee
+}
+
+// decodeAlarm turns alarms triggered before the event start into reminder overrides
+func decodeAlarm(event *calendar.Event, line *contentLine) error {
+	if (line.Name != "TRIGGER" || strings.EqualFold(line.Params["VALUE"], "DATE-TIME") || strings.EqualFold(line.Params["RELATED"], "END")) {
+		return nil
+	}
+	before, err := parseDuration(line.Value)
+	if (err != nil) {
+		return fmt.Errorf("invalid TRIGGER: %w", err)
+	}
+	if (before > 0) {
+		return nil
+	}
+
+	if (event.Reminders == nil) {
+		event.Reminders = &calendar.EventReminders{ ForceSendFields: []string{"UseDefault"} }
+	}
+	event.Reminders.Overrides = append(event.Reminders.Overrides, &calendar.EventReminder{
+		Method: "popup",
+		Minutes: int64(-before / time.Minute),
+		ForceSendFields: []string{"Minutes"},
+	})
+	return nil
+}
+
+// decodeDateTime supports DATE values, UTC date-times, date-times with TZID and floating date-times in local time
+func decodeDateTime(line *contentLine) (*calendar.EventDateTime, error) {
+	if (strings.EqualFold(line.Params["VALUE"], "DATE") || len(line.Value) == len(dateLayout)) {
+		date, err := time.Parse(dateLayout, line.Value)
+		if (err != nil) {
+			return nil, err
+		}
+		return &calendar.EventDateTime{ Date: date.Format(time.DateOnly) }, nil
+	}
+
+	if (strings.HasSuffix(line.Value, "Z")) {
+		value, err := time.Parse(utcDateTimeLayout, line.Value)
+		if (err != nil) {
+			return nil, err
+		}
+		return &calendar.EventDateTime{ DateTime: value.Format(time.RFC3339), TimeZone: "UTC" }, nil
+	}
+
+	location, zone, ok := lookupZone(line.Params["TZID"])
+	if (!ok) {
+		// floating times and unknown zones are taken in local time
+		location, zone = time.Local, localZoneName()
+	}
+	value, err := time.ParseInLocation(localDateTimeLayout, line.Value, location)
+	if (err != nil) {
+		return nil, err
+	}
+	if (zone == "") {
+		// Google rejects recurring events without a zone, UTC keeps the same instant
+		return &calendar.EventDateTime{ DateTime: value.UTC().Format(time.RFC3339), TimeZone: "UTC" }, nil
+	}
+	return &calendar.EventDateTime{ DateTime: value.Format(time.RFC3339), TimeZone: zone }, nil
+}
+
+// finishEvent validates the event and derives its end from DURATION when DTEND is missing
+func finishEvent(event *calendar.Event, duration *time.Duration) error {
+	if (event.Start == nil) {
+		return fmt.Errorf("event '%s' has no DTSTART", event.ICalUID)
+	}
+	if (event.End != nil) {
+		return nil
+	}
+
+	if (event.Start.Date != "") {
+		start, _ := time.Parse(time.DateOnly, event.Start.Date)
+		days := 1
+		if (duration != nil) {
+			days = max(1, int(*duration / (24 * time.Hour)))
+		}
+		event.End = &calendar.EventDateTime{ Date: start.AddDate(0, 0, days).Format(time.DateOnly) }
+		return nil
+	}
+
+	start, err := time.Parse(time.RFC3339, event.Start.DateTime)
+	if (err != nil) {
+		return err
+	}
+	end := start
+	if (duration != nil) {
+		end = start.Add(*duration)
+	}
+	event.End = &calendar.EventDateTime{ DateTime: end.Format(time.RFC3339), TimeZone: event.Start.TimeZone }
+	return nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	match := durationPattern.FindStringSubmatch(value)
+	if (match == nil || value == "P" || value == "PT") {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration time.Duration
+	for i, unit := range units {
+		if (match[i + 2] == "") {
+			continue
+		}
+		count, err := strconv.Atoi(match[i + 2])
+		if (err != nil) {
+			return 0, err
+		}
+		duration += time.Duration(count) * unit
+	}
+	if (match[1] == "-") {
+		duration = -duration
+	}
+	return duration, nil
+}
+
+func mailAddress(value string) string {
+	if (len(value) > len("mailto:") && strings.EqualFold(value[:len("mailto:")], "mailto:")) {
+		return value[len("mailto:"):]
+	}
+	return value
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func decode(t *testing.T, lines ...string) []*calendar.Event {
+	t.Helper()
+	text := strings.Join(append(append([]string{ "BEGIN:VCALENDAR", "VERSION:2.0" }, lines...), "END:VCALENDAR"), lineBreak)
+	events, err := Decode(strings.NewReader(text))
+	if (err != nil) {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	return events
+}
+
+func equalTime(a *calendar.EventDateTime, b *calendar.EventDateTime) bool {
+	return a != nil && b != nil && a.Date == b.Date && a.DateTime == b.DateTime && a.TimeZone == b.TimeZone
+}
+
+func TestRoundTrip(t *testing.T) {
+	original := &calendar.Event{
+		Id: "1",
+		ICalUID: "meeting-1@example.com",
+		Summary: "Planning, part 2",
+		Description: "Agenda:\n- budget\n- roadmap",
+		Location: "Room 3",
+		Start: &calendar.EventDateTime{ DateTime: "2024-05-06T10:00:00+02:00", TimeZone: "Europe/Berlin" },
+		End: &calendar.EventDateTime{ DateTime: "2024-05-06T11:30:00+02:00", TimeZone: "Europe/Berlin" },
+		Recurrence: []string{ "RRULE:FREQ=WEEKLY;BYDAY=MO;COUNT=4" },
+		Transparency: "transparent",
+		Organizer: &calendar.EventOrganizer{ Email: "boss@example.com", DisplayName: "Boss" },
+		Attendees: []*calendar.EventAttendee{
+			{ Email: "me@example.com", ResponseStatus: "accepted" },
+			{ Email: "room@example.com", Resource: true, Optional: true, ResponseStatus: "needsAction" },
+		},
+		Reminders: &calendar.EventReminders{ Overrides: []*calendar.EventReminder{ { Method: "popup", Minutes: 15 } } },
+	}
+
+	events, err := Decode(strings.NewReader(encode(t, original)))
+	if (err != nil) {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	if (len(events) != 1) {
+		t.Fatalf("decoded %d events, want 1", len(events))
+	}
+	decoded := events[0]
+
+	if (decoded.ICalUID != original.ICalUID || decoded.Summary != original.Summary || decoded.Description != original.Description || decoded.Location != original.Location) {
+		t.Errorf("texts differ: %+v", decoded)
+	}
+	if (!equalTime(decoded.Start, original.Start) || !equalTime(decoded.End, original.End)) {
+		t.Errorf("times differ: start %+v, end %+v", decoded.Start, decoded.End)
+	}
+	if (len(decoded.Recurrence) != 1 || decoded.Recurrence[0] != original.Recurrence[0]) {
+		t.Errorf("recurrence differs: %v", decoded.Recurrence)
+	}
+	if (decoded.Transparency != "transparent" || decoded.Organizer.Email != "boss@example.com" || decoded.Organizer.DisplayName != "Boss") {
+		t.Errorf("properties differ: %+v", decoded)
+	}
+	if (len(decoded.Attendees) != 2 || decoded.Attendees[0].ResponseStatus != "accepted" || !decoded.Attendees[1].Resource || !decoded.Attendees[1].Optional) {
+		t.Errorf("attendees differ: %+v", decoded.Attendees)
+	}
+	if (decoded.Reminders == nil || len(decoded.Reminders.Overrides) != 1 || decoded.Reminders.Overrides[0].Minutes != 15) {
+		t.Errorf("reminders differ: %+v", decoded.Reminders)
+	}
+}
+
+func TestDecodeDuration(t *testing.T) {
+	tests := []struct {
+		name	string
+		start	string
+		extra	[]string
+		want	calendar.EventDateTime
+	}{
+		{ "timed", "DTSTART:20240506T100000Z", []string{ "DURATION:PT1H30M" }, calendar.EventDateTime{ DateTime: "2024-05-06T11:30:00Z", TimeZone: "UTC" } },
+		{ "weeks", "DTSTART:20240506T100000Z", []string{ "DURATION:P1W" }, calendar.EventDateTime{ DateTime: "2024-05-13T10:00:00Z", TimeZone: "UTC" } },
+		{ "all day without end", "DTSTART;VALUE=DATE:20240506", nil, calendar.EventDateTime{ Date: "2024-05-07" } },
+		{ "all day with days", "DTSTART;VALUE=DATE:20240506", []string{ "DURATION:P3D" }, calendar.EventDateTime{ Date: "2024-05-09" } },
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := append([]string{ "BEGIN:VEVENT", "UID:1", test.start }, test.extra...)
+			events := decode(t, append(lines, "END:VEVENT")...)
+			if (!equalTime(events[0].End, &test.want)) {
+				t.Errorf("end = %+v, want %+v", *events[0].End, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT15M": 15 * time.Minute,
+		"-PT15M": -15 * time.Minute,
+		"P1DT2H": 26 * time.Hour,
+		"+P2W": 14 * 24 * time.Hour,
+	}
+	for value, want := range tests {
+		got, err := parseDuration(value)
+		if (err != nil || got != want) {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{ "P", "PT", "1H", "P1H" } {
+		if _, err := parseDuration(value); (err == nil) {
+			t.Errorf("parseDuration(%q) has to fail", value)
+		}
+	}
+}
+
+func TestDecodeZones(t *testing.T) {
+	t.Setenv("TZ", "America/New_York")
+	events := decode(t,
+		"BEGIN:VEVENT", "UID:1", `DTSTART;TZID="W. Europe Standard Time":20240506T100000`, "DTEND;TZID=Unknown/Zone:20240506T110000", "END:VEVENT",
+	)
+
+	if (!equalTime(events[0].Start, &calendar.EventDateTime{ DateTime: "2024-05-06T10:00:00+02:00", TimeZone: "Europe/Berlin" })) {
+		t.Errorf("Windows zone was not mapped: %+v", events[0].Start)
+	}
+	if (events[0].End.TimeZone == "") {
+		t.Errorf("unknown zones have to fall back to a named zone: %+v", events[0].End)
+	}
+}
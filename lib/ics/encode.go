@@ -0,0 +1,356 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"google.golang.org/api/calendar/v3"
+)
+
+// Encoder writes events as an RFC 5545 VCALENDAR
+type Encoder struct {
+	writer		*bufio.Writer
+	// Name is written as X-WR-CALNAME, which most clients show as the calendar title
+	Name		string
+	err			error
+	// cancelled holds original starts of cancelled occurrences by the key of their series
+	cancelled	map[string][]*calendar.EventDateTime
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{ writer: bufio.NewWriter(w) }
+}
+
+// Encode writes one VCALENDAR with all events and VTIMEZONE components for zones they use
+func (e *Encoder) Encode(events []*figevent.Event) error {
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:" + prodId)
+	e.line("CALSCALE:GREGORIAN")
+	e.line("METHOD:PUBLISH")
+	if (e.Name != "") {
+		e.property("X-WR-CALNAME", nil, escapeText(e.Name))
+	}
+
+	for _, zone := range collectZones(events) {
+		e.timeZone(zone)
+	}
+
+	// cancelled occurrences become EXDATE of their series, Google lists them without DTSTART
+	e.cancelled = make(map[string][]*calendar.EventDateTime)
+	for _, event := range events {
+		if (isCancelledOccurrence(event)) {
+			key := seriesKey(event, event.RecurringEventId)
+			e.cancelled[key] = append(e.cancelled[key], event.OriginalStartTime)
+		}
+	}
+
+	for _, event := range events {
+		if (!isCancelledOccurrence(event)) {
+			e.event(event)
+		}
+	}
+
+	e.line("END:VCALENDAR")
+	if (e.err != nil) {
+		return e.err
+	}
+	return e.writer.Flush()
+}
+
+func (e *Encoder) event(event *figevent.Event) {
+	e.line("BEGIN:VEVENT")
+
+	uid := event.ICalUID
+	if (uid == "") {
+		uid = event.Id + "@google.com"
+	}
+	e.property("UID", nil, escapeText(uid))
+
+	stamp := time.Now().UTC()
+	if (event.Updated != "") {
+		updated, err := time.Parse(time.RFC3339, event.Updated)
+		if (err == nil) {
+			stamp = updated.UTC()
+		}
+	}
+	e.property("DTSTAMP", nil, stamp.Format(utcDateTimeLayout))
+	e.timestamp("CREATED", event.Created)
+	e.timestamp("LAST-MODIFIED", event.Updated)
+
+	e.dateTime("DTSTART", event.Start)
+	if (!event.EndTimeUnspecified) {
+		e.dateTime("DTEND", event.End)
+	}
+	if (event.OriginalStartTime != nil && event.RecurringEventId != "") {
+		e.dateTime("RECURRENCE-ID", event.OriginalStartTime)
+	}
+	// Google stores recurrence as iCalendar lines already (RRULE, EXRULE, RDATE, EXDATE)
+	for _, rule := range event.Recurrence {
+		e.line(rule)
+	}
+	if (len(event.Recurrence) > 0) {
+		for _, originalStart := range e.cancelled[seriesKey(event, event.Id)] {
+			e.dateTime("EXDATE", originalStart)
+		}
+	}
+
+	e.text("SUMMARY", event.Summary)
+	e.text("DESCRIPTION", event.Description)
+	e.text("LOCATION", event.Location)
+	if (event.HtmlLink != "") {
+		e.property("URL", nil, event.HtmlLink)
+	}
+	if status, ok := eventStatuses[event.Status]; ok {
+		e.property("STATUS", nil, status)
+	}
+	if (event.Transparency == "transparent") {
+		e.property("TRANSP", nil, "TRANSPARENT")
+	} else {
+		e.property("TRANSP", nil, "OPAQUE")
+	}
+	if (event.Sequence > 0) {
+		e.property("SEQUENCE", nil, fmt.Sprintf("%d", event.Sequence))
+	}
+	if (event.Visibility == "private" || event.Visibility == "confidential") {
+		e.property("CLASS", nil, strings.ToUpper(event.Visibility))
+	}
+
+	if (event.Organizer != nil && event.Organizer.Email != "") {
+		e.property("ORGANIZER", params("CN", event.Organizer.DisplayName), "mailto:" + event.Organizer.Email)
+	}
+	for _, attendee := range event.Attendees {
+		e.attendee(attendee)
+	}
+
+	e.text("X-FIGORO-ACCOUNT", event.Account)
+	e.text("X-FIGORO-CALENDAR", event.CalendarName())
+
+	if (event.Reminders != nil) {
+		for _, reminder := range event.Reminders.Overrides {
+			e.alarm(event, reminder)
+		}
+	}
+
+	e.line("END:VEVENT")
+}
+
+func isCancelledOccurrence(event *figevent.Event) bool {
+	return event.Status == "cancelled" && event.RecurringEventId != "" && event.OriginalStartTime != nil
+}
+
+// seriesKey identifies a recurring event, IDs are unique only within a calendar
+func seriesKey(event *figevent.Event, seriesId string) string {
+	return strings.Join([]string{ event.Account, event.CalendarID, seriesId }, "\x00")
+}
+
+func (e *Encoder) attendee(attendee *calendar.EventAttendee) {
+	if (attendee.Email == "") {
+		return
+	}
+	role := "REQ-PARTICIPANT"
+	if (attendee.Optional) {
+		role = "OPT-PARTICIPANT"
+	}
+	cuType := "INDIVIDUAL"
+	if (attendee.Resource) {
+		cuType = "RESOURCE"
+	}
+	partStat := partStats[attendee.ResponseStatus]
+	if (partStat == "") {
+		partStat = "NEEDS-ACTION"
+	}
+	e.property("ATTENDEE", params("CN", attendee.DisplayName, "CUTYPE", cuType, "ROLE", role, "PARTSTAT", partStat), "mailto:" + attendee.Email)
+}
+
+func (e *Encoder) alarm(event *figevent.Event, reminder *calendar.EventReminder) {
+	e.line("BEGIN:VALARM")
+	e.property("ACTION", nil, "DISPLAY")
+	e.text("DESCRIPTION", event.Summary)
+	e.property("TRIGGER", nil, fmt.Sprintf("-PT%dM", reminder.Minutes))
+	e.line("END:VALARM")
+}
+
+func (e *Encoder) text(name string, value string) {
+	if (value != "") {
+		e.property(name, nil, escapeText(value))
+	}
+}
+
+func (e *Encoder) timestamp(name string, value string) {
+	if (value == "") {
+		return
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if (err == nil) {
+		e.property(name, nil, parsed.UTC().Format(utcDateTimeLayout))
+	}
+}
+
+// dateTime writes all-day events as DATE values, timed events in their own zone when known and in UTC otherwise
+func (e *Encoder) dateTime(name string, eventTime *calendar.EventDateTime) {
+	if (eventTime == nil) {
+		return
+	}
+	if (eventTime.DateTime == "") {
+		date, err := time.Parse(time.DateOnly, eventTime.Date)
+		if (err == nil) {
+			e.property(name, params("VALUE", "DATE"), date.Format(dateLayout))
+		}
+		return
+	}
+
+	parsed, err := time.Parse(time.RFC3339, eventTime.DateTime)
+	if (err != nil) {
+		e.err = fmt.Errorf("invalid %s '%s': %w", name, eventTime.DateTime, err)
+		return
+	}
+	location, err := time.LoadLocation(eventTime.TimeZone)
+	if (eventTime.TimeZone == "" || err != nil) {
+		e.property(name, nil, parsed.UTC().Format(utcDateTimeLayout))
+		return
+	}
+	e.property(name, params("TZID", eventTime.TimeZone), parsed.In(location).Format(localDateTimeLayout))
+}
+
+func (e *Encoder) property(name string, parameters []string, value string) {
+	var builder strings.Builder
+	builder.WriteString(name)
+	for _, parameter := range parameters {
+		builder.WriteString(";")
+		builder.WriteString(parameter)
+	}
+	builder.WriteString(":")
+	builder.WriteString(value)
+	e.line(builder.String())
+}
+
+// line writes a content line folding it at maxLineLength octets without splitting UTF-8 characters
+func (e *Encoder) line(line string) {
+	if (e.err != nil) {
+		return
+	}
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		_, e.err = e.writer.WriteString(line[:cut] + lineBreak + " ")
+		if (e.err != nil) {
+			return
+		}
+		line = line[cut:]
+		// continuation lines start with a space, which counts towards the limit
+		limit = maxLineLength - 1
+	}
+	_, e.err = e.writer.WriteString(line + lineBreak)
+}
+
+// params builds property parameters out of name and value pairs, skipping empty values
+func params(pairs ...string) []string {
+	parameters := make([]string, 0, len(pairs) / 2)
+	for i := 0; i + 1 < len(pairs); i += 2 {
+		if (pairs[i + 1] == "") {
+			continue
+		}
+		parameters = append(parameters, pairs[i] + "=" + paramValue(pairs[i + 1]))
+	}
+	return parameters
+}
+
+func paramValue(value string) string {
+	value = strings.ReplaceAll(value, "\"", "'")
+	if (strings.ContainsAny(value, ":;,")) {
+		return "\"" + value + "\""
+	}
+	return value
+}
+
+// collectZones returns zones used by events with the span of years they have to cover
+func collectZones(events []*figevent.Event) []*zoneSpan {
+	spans := make(map[string]*zoneSpan)
+	add := func(zone string, year int) {
+		if (zone == "") {
+			return
+		}
+		span, ok := spans[zone]
+		if (!ok) {
+			location, err := time.LoadLocation(zone)
+			if (err != nil) {
+				return
+			}
+			span = &zoneSpan{ Name: zone, Location: location, From: year, To: year }
+			spans[zone] = span
+		}
+		span.From = min(span.From, year)
+		span.To = max(span.To, year)
+	}
+
+	for _, event := range events {
+		for _, eventTime := range []*calendar.EventDateTime{event.Start, event.End, event.OriginalStartTime} {
+			if (eventTime == nil || eventTime.DateTime == "") {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, eventTime.DateTime)
+			if (err != nil) {
+				continue
+			}
+			add(eventTime.TimeZone, parsed.Year())
+			if (len(event.Recurrence) > 0) {
+				// recurring events continue after their first instance, cover a few years ahead
+				add(eventTime.TimeZone, max(parsed.Year(), time.Now().Year()) + recurrenceYears)
+			}
+		}
+		for _, rule := range event.Recurrence {
+			zone := ruleZone(rule)
+			if (zone != "" && event.Start != nil) {
+				start, err := time.Parse(time.RFC3339, event.Start.DateTime)
+				if (err == nil) {
+					add(zone, start.Year())
+				}
+			}
+		}
+	}
+
+	zones := make([]*zoneSpan, 0, len(spans))
+	for _, span := range spans {
+		zones = append(zones, span)
+	}
+	slices.SortFunc(zones, func(a, b *zoneSpan) int { return strings.Compare(a.Name, b.Name) })
+	return zones
+}
+
+// ruleZone extracts TZID parameter of recurrence lines like EXDATE;TZID=Europe/Berlin:20240513T100000
+func ruleZone(rule string) string {
+	head, _, _ := strings.Cut(rule, ":")
+	for _, parameter := range strings.Split(head, ";")[1:] {
+		name, value, ok := strings.Cut(parameter, "=")
+		if (ok && strings.EqualFold(name, "TZID")) {
+			return strings.Trim(value, "\"")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package ics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/EugeneShtoka/figoro/lib/figevent"
+	"google.golang.org/api/calendar/v3"
+)
+
+func encode(t *testing.T, events ...*calendar.Event) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	err := NewEncoder(&buffer).Encode(figevent.Wrap(figevent.Source{ Account: "work", CalendarID: "primary" }, events))
+	if (err != nil) {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	return buffer.String()
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	summary := strings.Repeat("Weekly sync über alles, ", 10)
+	output := encode(t, &calendar.Event{
+		Id: "1",
+		Summary: summary,
+		Start: &calendar.EventDateTime{ DateTime: "2024-05-06T10:00:00Z" },
+		End: &calendar.EventDateTime{ DateTime: "2024-05-06T11:00:00Z" },
+	})
+
+	for _, line := range strings.Split(strings.TrimSuffix(output, lineBreak), lineBreak) {
+		if (len(line) > maxLineLength) {
+			t.Errorf("line of %d octets is longer than %d: %q", len(line), maxLineLength, line)
+		}
+		if (!utf8.ValidString(line)) {
+			t.Errorf("line splits a UTF-8 character: %q", line)
+		}
+	}
+
+	events, err := Decode(strings.NewReader(output))
+	if (err != nil) {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	if (len(events) != 1 || events[0].Summary != summary) {
+		t.Errorf("folded summary was not restored, got %+v", events)
+	}
+}
+
+func TestEncodeCancelledOccurrencesAsExdate(t *testing.T) {
+	output := encode(t,
+		&calendar.Event{
+			Id: "series",
+			Summary: "Standup",
+			Start: &calendar.EventDateTime{ DateTime: "2024-05-06T10:00:00+02:00", TimeZone: "Europe/Berlin" },
+			End: &calendar.EventDateTime{ DateTime: "2024-05-06T10:15:00+02:00", TimeZone: "Europe/Berlin" },
+			Recurrence: []string{ "RRULE:FREQ=DAILY" },
+		},
+		&calendar.Event{
+			Id: "series_20240508T080000Z",
+			Status: "cancelled",
+			RecurringEventId: "series",
+			OriginalStartTime: &calendar.EventDateTime{ DateTime: "2024-05-08T10:00:00+02:00", TimeZone: "Europe/Berlin" },
+		},
+	)
+
+	if (!strings.Contains(output, "EXDATE;TZID=Europe/Berlin:20240508T100000\r\n")) {
+		t.Errorf("cancelled occurrence is missing from EXDATE:\n%s", output)
+	}
+	if (strings.Count(output, "BEGIN:VEVENT") != 1) {
+		t.Errorf("cancelled occurrence has to be left out as a VEVENT:\n%s", output)
+	}
+}
+
+func TestEncodeEscapesText(t *testing.T) {
+	output := encode(t, &calendar.Event{
+		Id: "1",
+		Summary: "Lunch; pizza, pasta\nand more",
+		Start: &calendar.EventDateTime{ Date: "2024-05-06" },
+		End: &calendar.EventDateTime{ Date: "2024-05-07" },
+	})
+
+	if (!strings.Contains(output, `SUMMARY:Lunch\; pizza\, pasta\nand more` + lineBreak)) {
+		t.Errorf("summary is not escaped:\n%s", output)
+	}
+	if (!strings.Contains(output, "DTSTART;VALUE=DATE:20240506\r\n")) {
+		t.Errorf("all-day start is not a DATE:\n%s", output)
+	}
+}
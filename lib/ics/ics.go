@@ -0,0 +1,61 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package ics
+
+import (
+	"strings"
+)
+
+var (
+	dateLayout = "20060102"
+	localDateTimeLayout = "20060102T150405"
+	utcDateTimeLayout = "20060102T150405Z"
+	lineBreak = "\r\n"
+	// lines longer than maxLineLength octets are folded as required by RFC 5545
+	maxLineLength = 75
+	prodId = "-//figoro//figoro//EN"
+
+	textEscaper = strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n")
+	textUnescaper = strings.NewReplacer("\\\\", "\\", "\\;", ";", "\\,", ",", "\\n", "\n", "\\N", "\n")
+
+	// statuses of Google events and attendees mapped to their iCalendar counterparts
+	eventStatuses = map[string]string{ "confirmed": "CONFIRMED", "tentative": "TENTATIVE", "cancelled": "CANCELLED" }
+	partStats = map[string]string{
+		"accepted": "ACCEPTED",
+		"declined": "DECLINED",
+		"tentative": "TENTATIVE",
+		"needsAction": "NEEDS-ACTION",
+	}
+)
+
+func escapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+func unescapeText(text string) string {
+	return textUnescaper.Replace(text)
+}
+
+// lookupValue returns the key of the map whose value is value
+func lookupValue(values map[string]string, value string) (string, bool) {
+	for key, v := range values {
+		if (v == value) {
+			return key, true
+		}
+	}
+	return "", false
+}
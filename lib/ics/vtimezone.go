@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package ics
+
+import (
+	"fmt"
+	"time"
+)
+
+// recurrenceYears is how many years ahead VTIMEZONE covers for recurring events
+const recurrenceYears = 2
+
+// zoneSpan is a time zone with the years which its VTIMEZONE has to describe
+type zoneSpan struct {
+	Name		string
+	Location	*time.Location
+	From		int
+	To			int
+}
+
+// transition is a change of UTC offset of a zone
+type transition struct {
+	At			time.Time
+	OffsetFrom	int
+	OffsetTo	int
+	Name		string
+	IsDST		bool
+}
+
+// timeZone writes VTIMEZONE with one observance per offset change found in the Go time zone database,
+// starting with the offset in effect at the beginning of the span
+func (e *Encoder) timeZone(span *zoneSpan) {
+	e.line("BEGIN:VTIMEZONE")
+	e.property("TZID", nil, span.Name)
+
+	start := time.Date(span.From, time.January, 1, 0, 0, 0, 0, span.Location)
+	end := time.Date(span.To + 1, time.January, 1, 0, 0, 0, 0, span.Location)
+	name, offset := start.Zone()
+	e.observance(transition{ At: start, OffsetFrom: offset, OffsetTo: offset, Name: name, IsDST: start.IsDST() })
+
+	for _, change := range transitions(span.Location, start, end) {
+		e.observance(change)
+	}
+
+	e.line("END:VTIMEZONE")
+}
+
+func (e *Encoder) observance(change transition) {
+	component := "STANDARD"
+	if (change.IsDST) {
+		component = "DAYLIGHT"
+	}
+	e.line("BEGIN:" + component)
+	// DTSTART of an observance is the local time according to the offset before the change
+	local := change.At.UTC().Add(time.Duration(change.OffsetFrom) * time.Second)
+	e.property("DTSTART", nil, local.Format(localDateTimeLayout))
+	e.property("TZOFFSETFROM", nil, formatOffset(change.OffsetFrom))
+	e.property("TZOFFSETTO", nil, formatOffset(change.OffsetTo))
+	if (change.Name != "") {
+		e.property("TZNAME", nil, escapeText(change.Name))
+	}
+	e.line("END:" + component)
+}
+
+// transitions scans the zone day by day and narrows every offset change down to the second
+func transitions(location *time.Location, start time.Time, end time.Time) []transition {
+	changes := make([]transition, 0)
+	_, offset := start.Zone()
+	for day := start; day.Before(end); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		_, nextOffset := next.Zone()
+		if (nextOffset == offset) {
+			continue
+		}
+
+		low, high := day, next
+		for high.Sub(low) > time.Second {
+			middle := low.Add(high.Sub(low) / 2)
+			if _, middleOffset := middle.Zone(); middleOffset == offset {
+				low = middle
+			} else {
+				high = middle
+			}
+		}
+
+		at := high.In(location)
+		name, _ := at.Zone()
+		changes = append(changes, transition{ At: at, OffsetFrom: offset, OffsetTo: nextOffset, Name: name, IsDST: at.IsDST() })
+		offset = nextOffset
+	}
+	return changes
+}
+
+func formatOffset(offset int) string {
+	sign := "+"
+	if (offset < 0) {
+		sign = "-"
+		offset = -offset
+	}
+	hours := offset / 3600
+	minutes := offset % 3600 / 60
+	seconds := offset % 60
+	if (seconds != 0) {
+		return fmt.Sprintf("%s%02d%02d%02d", sign, hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, hours, minutes)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package ics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// windowsZones maps zone names used by Outlook and Exchange to IANA zones, after the CLDR windowsZones table
+	windowsZones = map[string]string{
+		"Dateline Standard Time": "Etc/GMT+12",
+		"Hawaiian Standard Time": "Pacific/Honolulu",
+		"Alaskan Standard Time": "America/Anchorage",
+		"Pacific Standard Time": "America/Los_Angeles",
+		"US Mountain Standard Time": "America/Phoenix",
+		"Mountain Standard Time": "America/Denver",
+		"Central America Standard Time": "America/Guatemala",
+		"Central Standard Time": "America/Chicago",
+		"Central Standard Time (Mexico)": "America/Mexico_City",
+		"Canada Central Standard Time": "America/Regina",
+		"SA Pacific Standard Time": "America/Bogota",
+		"Eastern Standard Time": "America/New_York",
+		"Atlantic Standard Time": "America/Halifax",
+		"Newfoundland Standard Time": "America/St_Johns",
+		"E. South America Standard Time": "America/Sao_Paulo",
+		"Argentina Standard Time": "America/Buenos_Aires",
+		"UTC": "Etc/UTC",
+		"GMT Standard Time": "Europe/London",
+		"Greenwich Standard Time": "Atlantic/Reykjavik",
+		"W. Europe Standard Time": "Europe/Berlin",
+		"Central Europe Standard Time": "Europe/Budapest",
+		"Romance Standard Time": "Europe/Paris",
+		"Central European Standard Time": "Europe/Warsaw",
+		"W. Central Africa Standard Time": "Africa/Lagos",
+		"GTB Standard Time": "Europe/Bucharest",
+		"FLE Standard Time": "Europe/Kiev",
+		"E. Europe Standard Time": "Europe/Chisinau",
+		"Egypt Standard Time": "Africa/Cairo",
+		"South Africa Standard Time": "Africa/Johannesburg",
+		"Israel Standard Time": "Asia/Jerusalem",
+		"Turkey Standard Time": "Europe/Istanbul",
+		"Russian Standard Time": "Europe/Moscow",
+		"Arab Standard Time": "Asia/Riyadh",
+		"Arabian Standard Time": "Asia/Dubai",
+		"Iran Standard Time": "Asia/Tehran",
+		"Pakistan Standard Time": "Asia/Karachi",
+		"India Standard Time": "Asia/Calcutta",
+		"Nepal Standard Time": "Asia/Katmandu",
+		"Bangladesh Standard Time": "Asia/Dhaka",
+		"SE Asia Standard Time": "Asia/Bangkok",
+		"China Standard Time": "Asia/Shanghai",
+		"Singapore Standard Time": "Asia/Singapore",
+		"Taipei Standard Time": "Asia/Taipei",
+		"Tokyo Standard Time": "Asia/Tokyo",
+		"Korea Standard Time": "Asia/Seoul",
+		"AUS Central Standard Time": "Australia/Darwin",
+		"E. Australia Standard Time": "Australia/Brisbane",
+		"AUS Eastern Standard Time": "Australia/Sydney",
+		"New Zealand Standard Time": "Pacific/Auckland",
+	}
+)
+
+// lookupZone loads IANA zones and zones with Windows names, returning the IANA name of the zone
+func lookupZone(name string) (*time.Location, string, bool) {
+	if (name == "") {
+		return nil, "", false
+	}
+	if location, err := time.LoadLocation(name); (err == nil) {
+		return location, name, true
+	}
+	if iana, ok := windowsZones[name]; (ok) {
+		if location, err := time.LoadLocation(iana); (err == nil) {
+			return location, iana, true
+		}
+	}
+	return nil, "", false
+}
+
+// localZoneName returns the IANA name of the local zone, which Google requires for recurring events,
+// or an empty string when it cannot be told
+func localZoneName() string {
+	if zone, ok := os.LookupEnv("TZ"); (ok) {
+		zone = strings.TrimPrefix(zone, ":")
+		if (zone == "") {
+			return "UTC"
+		}
+		if _, err := time.LoadLocation(zone); (err == nil && !filepath.IsAbs(zone)) {
+			return zone
+		}
+		return ""
+	}
+	target, err := filepath.EvalSymlinks("/etc/localtime")
+	if (err != nil) {
+		return ""
+	}
+	_, zone, found := strings.Cut(target, "zoneinfo/")
+	if (!found) {
+		return ""
+	}
+	if _, err := time.LoadLocation(zone); (err != nil) {
+		return ""
+	}
+	return zone
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package interval
+
+import (
+	"slices"
+	"time"
+)
+
+// Interval is a half-open span of time [Start, End)
+type Interval struct {
+	Start	time.Time	`json:"start"`
+	End		time.Time	`json:"end"`
+}
+
+func New(start time.Time, end time.Time) Interval {
+	return Interval{ Start: start, End: end }
+}
+
+func (i Interval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+func (i Interval) Overlaps(other Interval) bool {
+	return i.Start.Before(other.End) && other.Start.Before(i.End)
+}
+
+// Merge sorts intervals and joins the overlapping and adjacent ones
+func Merge(intervals []Interval) []Interval {
+	sorted := slices.Clone(intervals)
+	slices.SortFunc(sorted, func(a, b Interval) int { return a.Start.Compare(b.Start) })
+
+	merged := make([]Interval, 0, len(sorted))
+	for _, current := range sorted {
+		if (!current.Start.Before(current.End)) {
+			continue
+		}
+		last := len(merged) - 1
+		if (last >= 0 && !current.Start.After(merged[last].End)) {
+			if (current.End.After(merged[last].End)) {
+				merged[last].End = current.End
+			}
+			continue
+		}
+		merged = append(merged, current)
+	}
+	return merged
+}
+
+// Expand widens the interval by margin on both sides
+func (i Interval) Expand(margin time.Duration) Interval {
+	return Interval{ Start: i.Start.Add(-margin), End: i.End.Add(margin) }
+}
+
+// Free returns the parts of the window which are not covered by busy intervals
+func Free(window Interval, busy []Interval) []Interval {
+	free := make([]Interval, 0)
+	cursor := window.Start
+	for _, current := range Merge(busy) {
+		if (!current.End.After(cursor)) {
+			continue
+		}
+		if (!current.Start.Before(window.End)) {
+			break
+		}
+		if (current.Start.After(cursor)) {
+			free = append(free, New(cursor, current.Start))
+		}
+		cursor = current.End
+	}
+	if (cursor.Before(window.End)) {
+		free = append(free, New(cursor, window.End))
+	}
+	return free
+}
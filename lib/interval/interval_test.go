@@ -0,0 +1,84 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package interval
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC)
+
+// at builds an interval from hours after base
+func at(start int, end int) Interval {
+	return New(base.Add(time.Duration(start) * time.Hour), base.Add(time.Duration(end) * time.Hour))
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name	string
+		input	[]Interval
+		want	[]Interval
+	}{
+		{ "empty", nil, []Interval{} },
+		{ "disjoint are sorted", []Interval{ at(5, 6), at(1, 2) }, []Interval{ at(1, 2), at(5, 6) } },
+		{ "overlapping", []Interval{ at(1, 4), at(3, 6) }, []Interval{ at(1, 6) } },
+		{ "adjacent", []Interval{ at(1, 2), at(2, 3) }, []Interval{ at(1, 3) } },
+		{ "contained", []Interval{ at(1, 10), at(2, 3), at(4, 5) }, []Interval{ at(1, 10) } },
+		{ "empty and inverted are dropped", []Interval{ at(2, 2), at(5, 4), at(6, 7) }, []Interval{ at(6, 7) } },
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Merge(test.input); (!slices.Equal(got, test.want)) {
+				t.Errorf("Merge() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFree(t *testing.T) {
+	window := at(9, 18)
+	tests := []struct {
+		name	string
+		busy	[]Interval
+		want	[]Interval
+	}{
+		{ "nothing busy", nil, []Interval{ at(9, 18) } },
+		{ "busy in the middle", []Interval{ at(12, 13) }, []Interval{ at(9, 12), at(13, 18) } },
+		{ "busy across the edges", []Interval{ at(8, 10), at(17, 19) }, []Interval{ at(10, 17) } },
+		{ "busy outside", []Interval{ at(1, 2), at(20, 21) }, []Interval{ at(9, 18) } },
+		{ "overlapping busy", []Interval{ at(10, 12), at(11, 14) }, []Interval{ at(9, 10), at(14, 18) } },
+		{ "all busy", []Interval{ at(0, 24) }, []Interval{} },
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Free(window, test.busy); (!slices.Equal(got, test.want)) {
+				t.Errorf("Free() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOverlapsIsHalfOpen(t *testing.T) {
+	if (at(1, 2).Overlaps(at(2, 3))) {
+		t.Errorf("adjacent intervals must not overlap")
+	}
+	if (!at(1, 3).Overlaps(at(2, 4))) {
+		t.Errorf("intervals sharing an hour must overlap")
+	}
+}
@@ -1,6 +1,8 @@
 package managedflag
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
@@ -24,6 +26,16 @@ type BoolFlag struct {
 	Value *bool
 }
 
+type StrSliceFlag struct {
+	baseFlag
+	Value *[]string
+}
+
+type DurationFlag struct {
+	baseFlag
+	Value *time.Duration
+}
+
 func (f *baseFlag)IsChanged() bool {
 	return f.cmd.Flags().Changed(f.name)
 }
@@ -62,4 +74,16 @@ func NewBoolP(cmd *cobra.Command, name string, shorthand string, defaultValue bo
 	var value *bool = new(bool)
 	cmd.Flags().BoolVarP(value, name, shorthand, defaultValue, usage)
 	return &BoolFlag{ baseFlag: baseFlag{cmd, name}, Value: value}
-}
\ No newline at end of file
+}
+
+func NewStrSlice(cmd *cobra.Command, name string, defaultValue []string, usage string) (*StrSliceFlag) {
+	var value *[]string = new([]string)
+	cmd.Flags().StringSliceVar(value, name, defaultValue, usage)
+	return &StrSliceFlag{ baseFlag: baseFlag{cmd, name}, Value: value}
+}
+
+func NewDuration(cmd *cobra.Command, name string, defaultValue time.Duration, usage string) (*DurationFlag) {
+	var value *time.Duration = new(time.Duration)
+	cmd.Flags().DurationVar(value, name, defaultValue, usage)
+	return &DurationFlag{ baseFlag: baseFlag{cmd, name}, Value: value}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package pattern
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern matches names exactly, by glob (*, ?, [...]) or by regular expression written as /expr/.
+// Unlike path.Match, globs match across slashes, which calendar summaries may contain
+type Pattern struct {
+	text	string
+	regexp	*regexp.Regexp
+}
+
+func Compile(text string) (*Pattern, error) {
+	if (len(text) > 1 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/")) {
+		re, err := regexp.Compile(text[1:len(text) - 1])
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid regular expression '%s': %w", text, err)
+		}
+		return &Pattern{ text: text, regexp: re }, nil
+	}
+
+	if (strings.ContainsAny(text, "*?[")) {
+		re, err := regexp.Compile(globToRegexp(text))
+		if (err != nil) {
+			return nil, fmt.Errorf("invalid glob '%s': %w", text, err)
+		}
+		return &Pattern{ text: text, regexp: re }, nil
+	}
+
+	return &Pattern{ text: text }, nil
+}
+
+func globToRegexp(glob string) string {
+	var builder strings.Builder
+	builder.WriteString("^")
+	inClass := false
+	for _, r := range glob {
+		switch {
+		case inClass:
+			if (r == ']') {
+				inClass = false
+			}
+			builder.WriteRune(r)
+		case r == '*':
+			builder.WriteString(".*")
+		case r == '?':
+			builder.WriteString(".")
+		case r == '[':
+			inClass = true
+			builder.WriteRune(r)
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
+
+// IsLiteral tells whether the pattern matches only the name equal to it
+func (p *Pattern) IsLiteral() bool {
+	return p.regexp == nil
+}
+
+func (p *Pattern) Match(name string) bool {
+	if (p.regexp != nil) {
+		return p.regexp.MatchString(name)
+	}
+	return p.text == name
+}
+
+func (p *Pattern) String() string {
+	return p.text
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package pattern
+
+import (
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob	string
+		want	string
+	}{
+		{ "Team*", `^Team.*$` },
+		{ "a?c", `^a.c$` },
+		{ "[ab]*", `^[ab].*$` },
+		{ "v1.0 (draft)*", `^v1\.0 \(draft\).*$` },
+		{ "[.*]", `^[.*]$` },
+	}
+	for _, test := range tests {
+		got := globToRegexp(test.glob)
+		if (got != test.want) {
+			t.Errorf("globToRegexp(%q) = %q, want %q", test.glob, got, test.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern	string
+		name	string
+		literal	bool
+		want	bool
+	}{
+		{ "work", "work", true, true },
+		{ "work", "Work", true, false },
+		{ "work", "workspace", true, false },
+		{ "/", "/", true, true },
+		{ "Team*", "Team A", false, true },
+		{ "Team*", "My Team", false, false },
+		{ "Team*", "Team/Backend", false, true },
+		{ "*/Backend", "Team/Backend", false, true },
+		{ "a?c", "abc", false, true },
+		{ "a?c", "ac", false, false },
+		{ "[ab]x", "bx", false, true },
+		{ "[ab]x", "cx", false, false },
+		{ "/^team-[0-9]+$/", "team-42", false, true },
+		{ "/^team-[0-9]+$/", "team-x", false, false },
+		{ "/holiday/", "de.german#holiday@group.v.calendar.google.com", false, true },
+	}
+	for _, test := range tests {
+		p, err := Compile(test.pattern)
+		if (err != nil) {
+			t.Fatalf("Compile(%q) failed: %v", test.pattern, err)
+		}
+		if (p.IsLiteral() != test.literal) {
+			t.Errorf("Compile(%q).IsLiteral() = %v, want %v", test.pattern, p.IsLiteral(), test.literal)
+		}
+		if (p.Match(test.name) != test.want) {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", test.pattern, test.name, !test.want, test.want)
+		}
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	for _, text := range []string{ "/(/", "[a" } {
+		_, err := Compile(text)
+		if (err == nil) {
+			t.Errorf("Compile(%q) has to fail", text)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package timeparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	layouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", time.DateOnly}
+	weekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "sun": time.Sunday,
+		"monday": time.Monday, "mon": time.Monday,
+		"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+		"wednesday": time.Wednesday, "wed": time.Wednesday,
+		"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+		"friday": time.Friday, "fri": time.Friday,
+		"saturday": time.Saturday, "sat": time.Saturday,
+	}
+	// Ranges lists the named periods understood by Range, besides days like 'today' or 'next friday'
+	Ranges = []string{"this-week", "next-week", "last-week", "this-month", "next-month", "last-month", "this-year", "next-year", "last-year"}
+)
+
+// Parser reads absolute times, offsets like '+2h' and natural language like 'next friday 18:00'.
+// Times without an offset are taken in Location, weeks start on WeekStart
+type Parser struct {
+	Now			time.Time
+	Location	*time.Location
+	WeekStart	time.Weekday
+}
+
+func New(location *time.Location, weekStart time.Weekday) *Parser {
+	return &Parser{
+		Now: time.Now().In(location),
+		Location: location,
+		WeekStart: weekStart,
+	}
+}
+
+// Parse reads a time, offsets are relative to Now
+func (p *Parser) Parse(text string) (time.Time, error) {
+	return p.ParseFrom(text, p.Now)
+}
+
+// ParseFrom reads a time, offsets like '+2h' or '-1d' are relative to base. It accepts:
+//   - RFC3339, '2006-01-02 15:04', '2006-01-02T15:04' and '2006-01-02'
+//   - 'now' and offsets with units s, m, h, d and w, e.g. '+1d12h'
+//   - days: 'today', 'tomorrow', 'yesterday', weekdays ('friday' is today or the coming one,
+//     'next friday' is after today, 'last friday' is before today) and 'this-week', 'next-month' and alike
+//   - any of the days followed by a time of day: '18:00', '6pm', '6:30pm', 'noon', 'midnight'
+func (p *Parser) ParseFrom(text string, base time.Time) (time.Time, error) {
+	return p.parse(text, base, false)
+}
+
+// ParseEndFrom reads the end of a period like ParseFrom, but days and dates without a time of day
+// stand for their end, so that 'friday' or '2024-05-10' include the whole day
+func (p *Parser) ParseEndFrom(text string, base time.Time) (time.Time, error) {
+	return p.parse(text, base, true)
+}
+
+func (p *Parser) parse(text string, base time.Time, end bool) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	for _, layout := range layouts {
+		parsed, err := time.ParseInLocation(layout, text, p.Location)
+		if (err == nil) {
+			if (end && layout == time.DateOnly) {
+				return parsed.AddDate(0, 0, 1), nil
+			}
+			return parsed, nil
+		}
+	}
+
+	lower := strings.ToLower(text)
+	if (lower == "now") {
+		return p.Now, nil
+	}
+	if (strings.HasPrefix(lower, "+") || strings.HasPrefix(lower, "-")) {
+		days, rest, err := parseOffset(lower)
+		if (err != nil) {
+			return time.Time{}, fmt.Errorf("invalid time '%s': %w", text, err)
+		}
+		return base.AddDate(0, 0, days).Add(rest), nil
+	}
+
+	fields := strings.Fields(lower)
+	if (len(fields) == 0) {
+		return time.Time{}, fmt.Errorf("time is empty")
+	}
+	hour, minute, hasClock := parseClock(fields[len(fields) - 1])
+	if (hasClock) {
+		fields = fields[:len(fields) - 1]
+	}
+
+	day, dayEnd := p.startOfDay(p.Now), p.startOfDay(p.Now).AddDate(0, 0, 1)
+	if (len(fields) > 0) {
+		var err error
+		day, dayEnd, err = p.period(strings.Join(fields, " "))
+		if (err != nil) {
+			return time.Time{}, fmt.Errorf("invalid time '%s': %w", text, err)
+		}
+	}
+	if (!hasClock) {
+		if (end) {
+			return dayEnd, nil
+		}
+		return day, nil
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, p.Location), nil
+}
+
+// Range returns the start and the end of a named period like 'this-week', or of a day like 'today' or 'next friday'
+func (p *Parser) Range(name string) (time.Time, time.Time, error) {
+	start, end, err := p.period(strings.ToLower(strings.TrimSpace(name)))
+	if (err != nil) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range '%s': %w", name, err)
+	}
+	return start, end, nil
+}
+
+// In returns the period from Now lasting the duration, which ends at Now for negative durations
+func (p *Parser) In(text string) (time.Time, time.Time, error) {
+	days, rest, err := parseOffset(strings.ToLower(strings.TrimSpace(text)))
+	if (err != nil) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid duration '%s': %w", text, err)
+	}
+	end := p.Now.AddDate(0, 0, days).Add(rest)
+	if (end.Before(p.Now)) {
+		return end, p.Now, nil
+	}
+	return p.Now, end, nil
+}
+
+// ParseDuration reads durations like time.ParseDuration does, adding units d for days and w for weeks
+func ParseDuration(text string) (time.Duration, error) {
+	days, rest, err := parseOffset(strings.ToLower(strings.TrimSpace(text)))
+	if (err != nil) {
+		return 0, fmt.Errorf("invalid duration '%s': %w", text, err)
+	}
+	return time.Duration(days) * 24 * time.Hour + rest, nil
+}
+
+// period returns the start and the end of a day or of a named period, both in Location
+func (p *Parser) period(name string) (time.Time, time.Time, error) {
+	name = strings.Join(strings.Fields(strings.ReplaceAll(name, "-", " ")), " ")
+	today := p.startOfDay(p.Now)
+
+	switch name {
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), today.AddDate(0, 0, 2), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	}
+
+	qualifier, unit, found := strings.Cut(name, " ")
+	if (!found) {
+		qualifier, unit = "", name
+	}
+	step := map[string]int{ "": 0, "this": 0, "next": 1, "last": -1 }
+	shift, known := step[qualifier]
+	if (!known) {
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown '%s', expected this, next or last", qualifier)
+	}
+
+	if weekday, isWeekday := weekdays[unit]; (isWeekday) {
+		ahead := (int(weekday) - int(today.Weekday()) + 7) % 7
+		switch {
+		case (shift == 1 && ahead == 0):
+			ahead = 7
+		case (shift == -1):
+			ahead = ahead - 7
+		}
+		day := today.AddDate(0, 0, ahead)
+		return day, day.AddDate(0, 0, 1), nil
+	}
+
+	if (qualifier == "") {
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown day '%s'", name)
+	}
+	switch unit {
+	case "week":
+		start := today.AddDate(0, 0, -((int(today.Weekday()) - int(p.WeekStart) + 7) % 7) + 7 * shift)
+		return start, start.AddDate(0, 0, 7), nil
+	case "month":
+		start := time.Date(today.Year(), today.Month() + time.Month(shift), 1, 0, 0, 0, 0, p.Location)
+		return start, start.AddDate(0, 1, 0), nil
+	case "year":
+		start := time.Date(today.Year() + shift, time.January, 1, 0, 0, 0, 0, p.Location)
+		return start, start.AddDate(1, 0, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period '%s', expected a day or one of %v", name, Ranges)
+}
+
+func (p *Parser) startOfDay(t time.Time) time.Time {
+	t = t.In(p.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.Location)
+}
+
+// parseClock reads a time of day: '18:00', '9:30', '6pm', '6:30am', 'noon' or 'midnight'
+func parseClock(text string) (int, int, bool) {
+	switch text {
+	case "noon":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+
+	maxHour, offset := 23, 0
+	if (strings.HasSuffix(text, "am") || strings.HasSuffix(text, "pm")) {
+		maxHour = 12
+		if (strings.HasSuffix(text, "pm")) {
+			offset = 12
+		}
+		text = text[:len(text) - 2]
+	} else if (!strings.Contains(text, ":")) {
+		// bare numbers are not times, '2024' is rather a year
+		return 0, 0, false
+	}
+
+	hourText, minuteText, hasMinutes := strings.Cut(text, ":")
+	hour, err := strconv.Atoi(hourText)
+	if (err != nil || hour < 0 || hour > maxHour) {
+		return 0, 0, false
+	}
+	minute := 0
+	if (hasMinutes) {
+		minute, err = strconv.Atoi(minuteText)
+		if (err != nil || len(minuteText) != 2 || minute > 59) {
+			return 0, 0, false
+		}
+	}
+	if (maxHour == 12) {
+		if (hour == 0) {
+			return 0, 0, false
+		}
+		hour = hour % 12 + offset
+	}
+	return hour, minute, true
+}
+
+// parseOffset reads a signed duration with units w, d, h, m and s, e.g. '+1w2d' or '-90m'.
+// Days and weeks are returned apart, so that they follow the calendar across daylight saving changes
+func parseOffset(text string) (int, time.Duration, error) {
+	sign := 1
+	switch {
+	case strings.HasPrefix(text, "+"):
+		text = text[1:]
+	case strings.HasPrefix(text, "-"):
+		sign, text = -1, text[1:]
+	}
+	if (text == "") {
+		return 0, 0, fmt.Errorf("duration is empty")
+	}
+
+	days := 0
+	var rest time.Duration
+	for (text != "") {
+		digits := 0
+		for (digits < len(text) && (text[digits] >= '0' && text[digits] <= '9' || text[digits] == '.')) {
+			digits++
+		}
+		unitEnd := digits
+		for (unitEnd < len(text) && text[unitEnd] >= 'a' && text[unitEnd] <= 'z') {
+			unitEnd++
+		}
+		if (digits == 0 || unitEnd == digits) {
+			return 0, 0, fmt.Errorf("expected a number with a unit [w, d, h, m, s], e.g. 3d or 2h30m")
+		}
+
+		number, unit := text[:digits], text[digits:unitEnd]
+		text = text[unitEnd:]
+		switch unit {
+		case "w", "d":
+			count, err := strconv.Atoi(number)
+			if (err != nil) {
+				return 0, 0, fmt.Errorf("days and weeks must be whole numbers")
+			}
+			if (unit == "w") {
+				count *= 7
+			}
+			days += count
+		default:
+			part, err := time.ParseDuration(number + unit)
+			if (err != nil) {
+				return 0, 0, err
+			}
+			rest += part
+		}
+	}
+	return sign * days, time.Duration(sign) * rest, nil
+}
@@ -0,0 +1,226 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package timeparse
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestParser is set to Wednesday 2024-05-08 14:30 in Berlin, with weeks starting on Monday
+func newTestParser(t *testing.T) *Parser {
+	t.Helper()
+	location, err := time.LoadLocation("Europe/Berlin")
+	if (err != nil) {
+		t.Skipf("time zone data is missing: %v", err)
+	}
+	return &Parser{
+		Now: time.Date(2024, time.May, 8, 14, 30, 0, 0, location),
+		Location: location,
+		WeekStart: time.Monday,
+	}
+}
+
+func TestParse(t *testing.T) {
+	p := newTestParser(t)
+	date := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, p.Location)
+	}
+	tests := []struct {
+		text	string
+		want	time.Time
+		wantEnd	time.Time
+	}{
+		{ "2024-05-10T09:00:00Z", time.Date(2024, time.May, 10, 9, 0, 0, 0, time.UTC), time.Date(2024, time.May, 10, 9, 0, 0, 0, time.UTC) },
+		{ "2024-05-10 09:15", date(time.May, 10, 9, 15), date(time.May, 10, 9, 15) },
+		{ "2024-05-10", date(time.May, 10, 0, 0), date(time.May, 11, 0, 0) },
+		{ "now", p.Now, p.Now },
+		{ "+1d2h", date(time.May, 9, 16, 30), date(time.May, 9, 16, 30) },
+		{ "today", date(time.May, 8, 0, 0), date(time.May, 9, 0, 0) },
+		{ "tomorrow 9:00", date(time.May, 9, 9, 0), date(time.May, 9, 9, 0) },
+		{ "friday", date(time.May, 10, 0, 0), date(time.May, 11, 0, 0) },
+		{ "wednesday", date(time.May, 8, 0, 0), date(time.May, 9, 0, 0) },
+		{ "next wednesday", date(time.May, 15, 0, 0), date(time.May, 16, 0, 0) },
+		{ "last monday 6pm", date(time.May, 6, 18, 0), date(time.May, 6, 18, 0) },
+		{ "next friday noon", date(time.May, 10, 12, 0), date(time.May, 10, 12, 0) },
+		{ "this-week", date(time.May, 6, 0, 0), date(time.May, 13, 0, 0) },
+		{ "next-month", date(time.June, 1, 0, 0), date(time.July, 1, 0, 0) },
+		{ "18:00", date(time.May, 8, 18, 0), date(time.May, 8, 18, 0) },
+	}
+	for _, test := range tests {
+		got, err := p.Parse(test.text)
+		if (err != nil || !got.Equal(test.want)) {
+			t.Errorf("Parse(%q) = %v, %v, want %v", test.text, got, err, test.want)
+		}
+		gotEnd, err := p.ParseEndFrom(test.text, p.Now)
+		if (err != nil || !gotEnd.Equal(test.wantEnd)) {
+			t.Errorf("ParseEndFrom(%q) = %v, %v, want %v", test.text, gotEnd, err, test.wantEnd)
+		}
+	}
+
+	for _, text := range []string{ "", "someday", "next fortnight", "13pm", "+3x" } {
+		_, err := p.Parse(text)
+		if (err == nil) {
+			t.Errorf("Parse(%q) has to fail", text)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	p := newTestParser(t)
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, p.Location)
+	}
+	tests := []struct {
+		name		string
+		weekStart	time.Weekday
+		start		time.Time
+		end			time.Time
+	}{
+		{ "this-week", time.Monday, date(2024, time.May, 6), date(2024, time.May, 13) },
+		{ "this-week", time.Sunday, date(2024, time.May, 5), date(2024, time.May, 12) },
+		{ "this-week", time.Saturday, date(2024, time.May, 4), date(2024, time.May, 11) },
+		{ "this-week", time.Wednesday, date(2024, time.May, 8), date(2024, time.May, 15) },
+		{ "next-week", time.Monday, date(2024, time.May, 13), date(2024, time.May, 20) },
+		{ "last-week", time.Monday, date(2024, time.April, 29), date(2024, time.May, 6) },
+		{ "this-month", time.Monday, date(2024, time.May, 1), date(2024, time.June, 1) },
+		{ "last-month", time.Monday, date(2024, time.April, 1), date(2024, time.May, 1) },
+		{ "next-year", time.Monday, date(2025, time.January, 1), date(2026, time.January, 1) },
+		{ "last-year", time.Monday, date(2023, time.January, 1), date(2024, time.January, 1) },
+		{ "Yesterday", time.Monday, date(2024, time.May, 7), date(2024, time.May, 8) },
+		{ "next friday", time.Monday, date(2024, time.May, 10), date(2024, time.May, 11) },
+	}
+	for _, test := range tests {
+		p.WeekStart = test.weekStart
+		start, end, err := p.Range(test.name)
+		if (err != nil || !start.Equal(test.start) || !end.Equal(test.end)) {
+			t.Errorf("Range(%q) with weeks from %v = %v - %v, %v, want %v - %v", test.name, test.weekStart, start, end, err, test.start, test.end)
+		}
+	}
+}
+
+func TestRangeAcrossYear(t *testing.T) {
+	p := newTestParser(t)
+	p.Now = time.Date(2024, time.December, 31, 10, 0, 0, 0, p.Location)
+
+	start, end, err := p.Range("next-month")
+	want := time.Date(2025, time.January, 1, 0, 0, 0, 0, p.Location)
+	if (err != nil || !start.Equal(want) || !end.Equal(want.AddDate(0, 1, 0))) {
+		t.Errorf("Range(next-month) on New Year's Eve = %v - %v, %v, want January 2025", start, end, err)
+	}
+}
+
+func TestIn(t *testing.T) {
+	p := newTestParser(t)
+	start, end, err := p.In("-2d")
+	if (err != nil || !start.Equal(p.Now.AddDate(0, 0, -2)) || !end.Equal(p.Now)) {
+		t.Errorf("In(-2d) = %v - %v, %v, want the two days before now", start, end, err)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		text	string
+		hour	int
+		minute	int
+		ok		bool
+	}{
+		{ "18:00", 18, 0, true },
+		{ "9:30", 9, 30, true },
+		{ "0:05", 0, 5, true },
+		{ "noon", 12, 0, true },
+		{ "midnight", 0, 0, true },
+		{ "12am", 0, 0, true },
+		{ "12pm", 12, 0, true },
+		{ "12:30am", 0, 30, true },
+		{ "1am", 1, 0, true },
+		{ "6:30pm", 18, 30, true },
+		{ "11pm", 23, 0, true },
+		{ "0am", 0, 0, false },
+		{ "13pm", 0, 0, false },
+		{ "24:00", 0, 0, false },
+		{ "9:60", 0, 0, false },
+		{ "9:5", 0, 0, false },
+		{ "2024", 0, 0, false },
+		{ "friday", 0, 0, false },
+	}
+	for _, test := range tests {
+		hour, minute, ok := parseClock(test.text)
+		if (ok != test.ok || hour != test.hour || minute != test.minute) {
+			t.Errorf("parseClock(%q) = %d, %d, %v, want %d, %d, %v", test.text, hour, minute, ok, test.hour, test.minute, test.ok)
+		}
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		text	string
+		days	int
+		rest	time.Duration
+		ok		bool
+	}{
+		{ "+2h", 0, 2 * time.Hour, true },
+		{ "-90m", 0, -90 * time.Minute, true },
+		{ "3d", 3, 0, true },
+		{ "+1w2d", 9, 0, true },
+		{ "-1d12h", -1, -12 * time.Hour, true },
+		{ "1.5h", 0, 90 * time.Minute, true },
+		{ "1.5d", 0, 0, false },
+		{ "+", 0, 0, false },
+		{ "2", 0, 0, false },
+		{ "h", 0, 0, false },
+		{ "2y", 0, 0, false },
+	}
+	for _, test := range tests {
+		days, rest, err := parseOffset(test.text)
+		if ((err == nil) != test.ok || days != test.days || rest != test.rest) {
+			t.Errorf("parseOffset(%q) = %d, %v, %v, want %d, %v", test.text, days, rest, err, test.days, test.rest)
+		}
+	}
+}
+
+func TestWeekStartOf(t *testing.T) {
+	tests := []struct {
+		locale	string
+		want	time.Weekday
+	}{
+		{ "en_US.UTF-8", time.Sunday },
+		{ "de_DE.UTF-8", time.Monday },
+		{ "he-IL", time.Sunday },
+		{ "ar_EG.UTF-8@latin", time.Saturday },
+		{ "en_gb", time.Monday },
+		{ "C", time.Monday },
+		{ "POSIX", time.Monday },
+	}
+	for _, test := range tests {
+		got := WeekStartOf(test.locale)
+		if (got != test.want) {
+			t.Errorf("WeekStartOf(%q) = %v, want %v", test.locale, got, test.want)
+		}
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	got, err := ParseWeekday(" Sun ")
+	if (err != nil || got != time.Sunday) {
+		t.Errorf("ParseWeekday(Sun) = %v, %v, want Sunday", got, err)
+	}
+	_, err = ParseWeekday("someday")
+	if (err == nil) {
+		t.Errorf("ParseWeekday(someday) has to fail")
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package timeparse
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// regions where weeks start on Sunday or Saturday, according to CLDR. Weeks start on Monday elsewhere
+	sundayRegions = []string{"AG", "AS", "BD", "BR", "BS", "BT", "BW", "BZ", "CA", "CN", "CO", "DM", "DO", "ET", "GT", "GU", "HK", "HN", "ID", "IL", "IN", "JM", "JP", "KE", "KH", "KR", "LA", "MH", "MM", "MO", "MT", "MX", "MZ", "NI", "NP", "PA", "PE", "PH", "PK", "PR", "PT", "PY", "SA", "SG", "SV", "TH", "TT", "TW", "UM", "US", "VE", "VI", "WS", "YE", "ZA", "ZW"}
+	saturdayRegions = []string{"AE", "AF", "BH", "DJ", "DZ", "EG", "IQ", "IR", "JO", "KW", "LY", "OM", "QA", "SD", "SY"}
+)
+
+// LocaleWeekStart returns the first day of the week for the locale in LC_ALL, LC_TIME or LANG
+func LocaleWeekStart() time.Weekday {
+	for _, name := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if locale := os.Getenv(name); (locale != "") {
+			return WeekStartOf(locale)
+		}
+	}
+	return time.Monday
+}
+
+// WeekStartOf returns the first day of the week for a locale like 'en_US.UTF-8' or 'he-IL'
+func WeekStartOf(locale string) time.Weekday {
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	_, region, found := strings.Cut(strings.ReplaceAll(locale, "-", "_"), "_")
+	if (!found) {
+		return time.Monday
+	}
+
+	region = strings.ToUpper(region)
+	switch {
+	case slices.Contains(sundayRegions, region):
+		return time.Sunday
+	case slices.Contains(saturdayRegions, region):
+		return time.Saturday
+	}
+	return time.Monday
+}
+
+// ParseWeekday reads a weekday name like 'monday' or 'mon'
+func ParseWeekday(name string) (time.Weekday, error) {
+	weekday, found := weekdays[strings.ToLower(strings.TrimSpace(name))]
+	if (!found) {
+		return 0, fmt.Errorf("unknown weekday '%s'", name)
+	}
+	return weekday, nil
+}
@@ -0,0 +1,243 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package typedkeyring
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/EugeneShtoka/figoro/lib/filelock"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters recommended for interactive logins
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	keySize = 32
+	saltSize = 16
+	nonceSize = 24
+	lockTimeout = 10 * time.Second
+)
+
+// encryptedFile is the layout of the file on disk, byte slices are base64 encoded by encoding/json
+type encryptedFile struct {
+	Salt	[]byte	`json:"salt"`
+	Nonce	[]byte	`json:"nonce"`
+	Box		[]byte	`json:"box"`
+}
+
+// fileBackend keeps values in a single file encrypted with a key derived from a passphrase,
+// for machines without a keyring service such as headless servers and containers
+type fileBackend struct {
+	path		string
+	passphrase	PassphraseFunc
+
+	mu			sync.Mutex
+	// key and salt are derived once, the passphrase is asked at most once per process
+	key			*[keySize]byte
+	salt		[]byte
+}
+
+// PassphraseFunc returns the passphrase of the secrets file, confirm is set when the file is created,
+// so that a mistyped passphrase does not lock the user out
+type PassphraseFunc func(confirm bool) (string, error)
+
+// NewFileBackend stores values in the file at path, passphrase is called when the file is first accessed
+func NewFileBackend(path string, passphrase PassphraseFunc) Backend {
+	return &fileBackend{ path: path, passphrase: passphrase }
+}
+
+func (b *fileBackend) Get(service string, name string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	values, err := b.read()
+	if (err != nil) {
+		return "", err
+	}
+	value, ok := values[service][name]
+	if (!ok) {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (b *fileBackend) Set(service string, name string, value string) error {
+	return b.update(func(values map[string]map[string]string) error {
+		if (values[service] == nil) {
+			values[service] = make(map[string]string)
+		}
+		values[service][name] = value
+		return nil
+	})
+}
+
+func (b *fileBackend) Delete(service string, name string) error {
+	return b.update(func(values map[string]map[string]string) error {
+		if _, ok := values[service][name]; !ok {
+			return ErrNotFound
+		}
+		delete(values[service], name)
+		return nil
+	})
+}
+
+// update changes the values under a lock shared with other processes, so that their changes are not lost
+func (b *fileBackend) update(change func(values map[string]map[string]string) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	unlock, err := filelock.Lock(b.path + ".lock", lockTimeout)
+	if (err != nil) {
+		return err
+	}
+	defer unlock()
+
+	values, err := b.read()
+	if (err != nil) {
+		return err
+	}
+	err = change(values)
+	if (err != nil) {
+		return err
+	}
+	return b.write(values)
+}
+
+func (b *fileBackend) read() (map[string]map[string]string, error) {
+	data, err := os.ReadFile(b.path)
+	if (errors.Is(err, os.ErrNotExist)) {
+		return make(map[string]map[string]string), nil
+	}
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	var file encryptedFile
+	err = json.Unmarshal(data, &file)
+	if (err != nil || len(file.Nonce) != nonceSize) {
+		return nil, fmt.Errorf("secrets file '%s' is corrupted", b.path)
+	}
+
+	key, err := b.deriveKey(file.Salt, false)
+	if (err != nil) {
+		return nil, err
+	}
+	var nonce [nonceSize]byte
+	copy(nonce[:], file.Nonce)
+	plain, ok := secretbox.Open(nil, file.Box, &nonce, key)
+	if (!ok) {
+		// forget the key, so that the passphrase can be entered again
+		b.key = nil
+		return nil, fmt.Errorf("wrong passphrase for secrets file '%s'", b.path)
+	}
+
+	values := make(map[string]map[string]string)
+	err = json.Unmarshal(plain, &values)
+	if (err != nil) {
+		return nil, fmt.Errorf("secrets file '%s' is corrupted: %w", b.path, err)
+	}
+	return values, nil
+}
+
+func (b *fileBackend) write(values map[string]map[string]string) error {
+	plain, err := json.Marshal(values)
+	if (err != nil) {
+		return err
+	}
+
+	salt := b.salt
+	created := (salt == nil)
+	if (created) {
+		salt = make([]byte, saltSize)
+		_, err = rand.Read(salt)
+		if (err != nil) {
+			return err
+		}
+	}
+	key, err := b.deriveKey(salt, created)
+	if (err != nil) {
+		return err
+	}
+	var nonce [nonceSize]byte
+	_, err = rand.Read(nonce[:])
+	if (err != nil) {
+		return err
+	}
+
+	data, err := json.Marshal(encryptedFile{
+		Salt: salt,
+		Nonce: nonce[:],
+		Box: secretbox.Seal(nil, plain, &nonce, key),
+	})
+	if (err != nil) {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(b.path), 0700)
+	if (err != nil) {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path) + ".*")
+	if (err != nil) {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if (err != nil) {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	err = os.Rename(temp.Name(), b.path)
+	if (err != nil) {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	return nil
+}
+
+// deriveKey asks for the passphrase on first use, the key is reused while the salt stays the same
+func (b *fileBackend) deriveKey(salt []byte, confirm bool) (*[keySize]byte, error) {
+	if (b.key != nil && string(b.salt) == string(salt)) {
+		return b.key, nil
+	}
+
+	passphrase, err := b.passphrase(confirm)
+	if (err != nil) {
+		return nil, fmt.Errorf("failed to get passphrase for secrets file: %w", err)
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if (err != nil) {
+		return nil, err
+	}
+
+	var key [keySize]byte
+	copy(key[:], derived)
+	b.key = &key
+	b.salt = salt
+	return b.key, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package typedkeyring
+
+import (
+	"sync"
+)
+
+// memoryBackend keeps values for the lifetime of the process, it is meant for tests
+type memoryBackend struct {
+	mu		sync.Mutex
+	values	map[string]string
+}
+
+func NewMemoryBackend() Backend {
+	return &memoryBackend{ values: make(map[string]string) }
+}
+
+func memoryKey(service string, name string) string {
+	return service + "\x00" + name
+}
+
+func (b *memoryBackend) Get(service string, name string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	value, ok := b.values[memoryKey(service, name)]
+	if (!ok) {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (b *memoryBackend) Set(service string, name string, value string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.values[memoryKey(service, name)] = value
+	return nil
+}
+
+func (b *memoryBackend) Delete(service string, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := memoryKey(service, name)
+	if _, ok := b.values[key]; !ok {
+		return ErrNotFound
+	}
+	delete(b.values, key)
+	return nil
+}
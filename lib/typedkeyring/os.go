@@ -0,0 +1,50 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package typedkeyring
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+// osBackend keeps values in the keyring of the OS: Keychain, Credential Manager or Secret Service
+type osBackend struct {}
+
+func NewOSBackend() Backend {
+	return osBackend{}
+}
+
+func (osBackend) Get(service string, name string) (string, error) {
+	value, err := keyring.Get(service, name)
+	return value, translateNotFound(err)
+}
+
+func (osBackend) Set(service string, name string, value string) error {
+	return keyring.Set(service, name, value)
+}
+
+func (osBackend) Delete(service string, name string) error {
+	return translateNotFound(keyring.Delete(service, name))
+}
+
+func translateNotFound(err error) error {
+	if (errors.Is(err, keyring.ErrNotFound)) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -18,21 +18,43 @@ package typedkeyring
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-
-	"github.com/zalando/go-keyring"
 )
 
+// ErrNotFound is returned when there is no value saved under the name
+var ErrNotFound = errors.New("secret not found")
+
+// Backend stores serialized values by service and name
+type Backend interface {
+	Get(service string, name string) (string, error)
+	Set(service string, name string, value string) error
+	Delete(service string, name string) error
+}
+
+// defaultBackend is used by keyrings created with New
+var defaultBackend Backend = NewOSBackend()
+
+// SetDefaultBackend selects the backend of keyrings created with New afterwards
+func SetDefaultBackend(backend Backend) {
+	defaultBackend = backend
+}
+
 type Keyring[T any] struct {
 	ServiceName		string
+	Backend			Backend
 }
 
 func New[T any](serviceName string) *Keyring[T] {
-    return &Keyring[T]{ ServiceName: serviceName }
+    return NewWithBackend[T](serviceName, defaultBackend)
+}
+
+func NewWithBackend[T any](serviceName string, backend Backend) *Keyring[T] {
+    return &Keyring[T]{ ServiceName: serviceName, Backend: backend }
 }
 
 func (k *Keyring[T]) Delete(name string) error {
-    err := keyring.Delete(k.ServiceName, name)
+    err := k.Backend.Delete(k.ServiceName, name)
     if err != nil {
         return fmt.Errorf("failed to delete token '%s': %w", name, err)
     }
@@ -41,7 +63,7 @@ func (k *Keyring[T]) Delete(name string) error {
 }
 
 func (k *Keyring[T]) Load(name string) (*T, error) {
-	data, err := keyring.Get(k.ServiceName, name)
+	data, err := k.Backend.Get(k.ServiceName, name)
 	if err != nil {
         return nil, fmt.Errorf("failed to load token: %w", err)
     }
@@ -49,7 +71,7 @@ func (k *Keyring[T]) Load(name string) (*T, error) {
 	var value T
     err = json.Unmarshal([]byte(data), &value)
     if err != nil {
-        return nil, fmt.Errorf("error deserializing object: %w" + err.Error())
+        return nil, fmt.Errorf("error deserializing object: %w", err)
     }
 
     return &value, nil
@@ -62,10 +84,10 @@ func (k *Keyring[T])Save(name string, value *T) error {
     }
 
     jsonStr := string(jsonData) 
-	err = keyring.Set(k.ServiceName, name, jsonStr)
+	err = k.Backend.Set(k.ServiceName, name, jsonStr)
 	if err != nil {
         return fmt.Errorf("failed to save token: %w", err)
     }
 
 	return nil
-}
\ No newline at end of file
+}
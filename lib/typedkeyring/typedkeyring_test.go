@@ -0,0 +1,144 @@
+/*
+Copyright © 2024 Eugene Shtoka <eshtoka@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package typedkeyring
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type secret struct {
+	Token	string
+}
+
+// passphrase returns a PassphraseFunc counting confirmations, as a new file asks for one
+func passphrase(value string, confirmations *int) PassphraseFunc {
+	return func(confirm bool) (string, error) {
+		if (confirm && confirmations != nil) {
+			*confirmations++
+		}
+		return value, nil
+	}
+}
+
+func testBackend(t *testing.T, backend Backend) {
+	_, err := backend.Get("figoro", "work")
+	if (!errors.Is(err, ErrNotFound)) {
+		t.Fatalf("Get() of a missing value = %v, want ErrNotFound", err)
+	}
+
+	keyring := NewWithBackend[secret]("figoro", backend)
+	err = keyring.Save("work", &secret{ Token: "abc" })
+	if (err != nil) {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	loaded, err := keyring.Load("work")
+	if (err != nil || loaded.Token != "abc") {
+		t.Fatalf("Load() = %v, %v, want the saved value", loaded, err)
+	}
+
+	err = keyring.Delete("work")
+	if (err != nil) {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	err = backend.Delete("figoro", "work")
+	if (!errors.Is(err, ErrNotFound)) {
+		t.Fatalf("Delete() of a missing value = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	testBackend(t, NewMemoryBackend())
+}
+
+func TestFileBackend(t *testing.T) {
+	testBackend(t, NewFileBackend(filepath.Join(t.TempDir(), "secrets.enc"), passphrase("secret", nil)))
+}
+
+func TestFileBackendConfirmsNewPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	confirmations := 0
+	backend := NewFileBackend(path, passphrase("secret", &confirmations))
+
+	for _, name := range []string{"work", "home"} {
+		err := backend.Set("figoro", name, "value")
+		if (err != nil) {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	if (confirmations != 1) {
+		t.Errorf("passphrase was confirmed %d times, want once when the file is created", confirmations)
+	}
+}
+
+func TestFileBackendWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	err := NewFileBackend(path, passphrase("secret", nil)).Set("figoro", "work", "value")
+	if (err != nil) {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	wrong := NewFileBackend(path, passphrase("typo", nil))
+	_, err = wrong.Get("figoro", "work")
+	if (err == nil || errors.Is(err, ErrNotFound)) {
+		t.Fatalf("Get() with a wrong passphrase = %v, want a passphrase error", err)
+	}
+	err = wrong.Set("figoro", "home", "value")
+	if (err == nil) {
+		t.Fatalf("Set() with a wrong passphrase has to fail instead of replacing the file")
+	}
+
+	value, err := NewFileBackend(path, passphrase("secret", nil)).Get("figoro", "work")
+	if (err != nil || value != "value") {
+		t.Fatalf("Get() after a wrong passphrase = %q, %v, want the value kept", value, err)
+	}
+}
+
+func TestFileBackendConcurrentUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	err := NewFileBackend(path, passphrase("secret", nil)).Set("figoro", "seed", "value")
+	if (err != nil) {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	// separate backends stand for separate processes sharing the file
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backend := NewFileBackend(path, passphrase("secret", nil))
+			errs[i] = backend.Set("figoro", fmt.Sprintf("account%d", i), "value")
+		}()
+	}
+	wg.Wait()
+
+	backend := NewFileBackend(path, passphrase("secret", nil))
+	for i, err := range errs {
+		if (err != nil) {
+			t.Fatalf("Set() of account%d failed: %v", i, err)
+		}
+		_, err = backend.Get("figoro", fmt.Sprintf("account%d", i))
+		if (err != nil) {
+			t.Errorf("update of account%d was lost: %v", i, err)
+		}
+	}
+}